@@ -0,0 +1,246 @@
+package main
+
+// API tokens, only their SHA-256 hashes are stored in DB, optionally restricted to some ISPs/accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Tokens prefix, it makes them easy to spot in leaked files
+const tokenPrefix = "ds_"
+
+var errTokenNotFound = errors.New("Token not found")
+
+type apiToken struct {
+	Name string
+	// Empty allows all of them
+	ISPs     []string
+	Accounts []string
+	Created  string
+}
+
+func createTokensTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS api_tokens ( "name" VARCHAR(100) PRIMARY KEY, "hash" VARCHAR(64) UNIQUE, "isps" TEXT DEFAULT '', "accounts" TEXT DEFAULT '', "created" VARCHAR(30));`)
+	return err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Comma separated list, empty values are ignored
+func splitList(list string) []string {
+	values := []string{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Create token, it is returned only once, DB keeps its hash
+func addToken(db *sql.DB, name string, isps, accounts []string) (string, error) {
+	if err := createTokensTable(db); err != nil {
+		return "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := tokenPrefix + hex.EncodeToString(secret)
+	_, err := db.Exec("INSERT INTO api_tokens (name, hash, isps, accounts, created) VALUES (?, ?, ?, ?, ?)",
+		name, hashToken(token), strings.Join(isps, ","), strings.Join(accounts, ","), time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func listTokens(db *sql.DB) ([]apiToken, error) {
+	if err := createTokensTable(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT name, isps, accounts, created FROM api_tokens ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []apiToken{}
+	for rows.Next() {
+		var token apiToken
+		var isps, accounts string
+		if err := rows.Scan(&token.Name, &isps, &accounts, &token.Created); err != nil {
+			return nil, err
+		}
+		token.ISPs = splitList(isps)
+		token.Accounts = splitList(accounts)
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func revokeToken(db *sql.DB, name string) error {
+	if err := createTokensTable(db); err != nil {
+		return err
+	}
+	result, err := db.Exec("DELETE FROM api_tokens WHERE name=?", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errTokenNotFound
+	}
+	return nil
+}
+
+// Get token by its value
+func findToken(db *sql.DB, token string) (*apiToken, error) {
+	var found apiToken
+	var isps, accounts string
+	err := db.QueryRow("SELECT name, isps, accounts, created FROM api_tokens WHERE hash=?", hashToken(token)).Scan(&found.Name, &isps, &accounts, &found.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errTokenNotFound
+	}
+	if err != nil {
+		// No tokens table, no tokens created yet
+		if strings.Contains(err.Error(), "no such table") {
+			return nil, errTokenNotFound
+		}
+		return nil, err
+	}
+	found.ISPs = splitList(isps)
+	found.Accounts = splitList(accounts)
+	return &found, nil
+}
+
+// Check if token can see domain holder account, accounts match id or realId
+func (t *apiToken) allows(record domainRecord) bool {
+	if len(t.ISPs) > 0 && !contains(t.ISPs, record.ISP) {
+		return false
+	}
+	if len(t.Accounts) > 0 && !contains(t.Accounts, record.ID) && !contains(t.Accounts, record.RealID) {
+		return false
+	}
+	return true
+}
+
+// SQL condition restricting domain_list rows to token ones, always true for unrestricted tokens
+func (t *apiToken) where() (string, []interface{}) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+	if t == nil {
+		return conditions[0], args
+	}
+	if len(t.ISPs) > 0 {
+		conditions = append(conditions, "isp IN ("+placeholders(len(t.ISPs))+")")
+		for _, isp := range t.ISPs {
+			args = append(args, isp)
+		}
+	}
+	if len(t.Accounts) > 0 {
+		conditions = append(conditions, "(id IN ("+placeholders(len(t.Accounts))+") OR realId IN ("+placeholders(len(t.Accounts))+"))")
+		for i := 0; i < 2; i++ {
+			for _, account := range t.Accounts {
+				args = append(args, account)
+			}
+		}
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Get token sent in Authorization: Bearer TOKEN or X-API-Key: TOKEN headers
+func requestToken(r *http.Request) string {
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+type tokenContextKey struct{}
+
+// Authenticated request token, nil when authentication is disabled
+func contextToken(ctx context.Context) *apiToken {
+	token, _ := ctx.Value(tokenContextKey{}).(*apiToken)
+	return token
+}
+
+// Response writer recording status for access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Paths without authentication: load balancers probes and web UI files, web UI asks for a token itself
+func publicPath(path string) bool {
+	return path == "/health" || path == "/" || strings.HasPrefix(path, webPrefix)
+}
+
+// Authenticate requests and log who looked up what
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		tokenName := "-"
+
+		switch {
+		case !s.auth || publicPath(r.URL.Path):
+			next.ServeHTTP(recorder, r)
+		default:
+			token, err := s.findToken(requestToken(r))
+			switch {
+			case errors.Is(err, errTokenNotFound):
+				recorder.Header().Set("WWW-Authenticate", `Bearer realm="domainSearcher"`)
+				writeJSONError(recorder, http.StatusUnauthorized, "Invalid or missing token")
+			case err != nil:
+				logger.Error("Unable to check token", "operation", "api_auth", "error", err)
+				writeJSONError(recorder, http.StatusInternalServerError, "DB error")
+			default:
+				tokenName = token.Name
+				next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token)))
+			}
+		}
+
+		s.accessLog.Info("API access", "operation", "api_access", "token", tokenName, "remote", r.RemoteAddr,
+			"method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery, "status", recorder.status, "duration", time.Since(start))
+	})
+}
+
+func (s *apiServer) findToken(token string) (*apiToken, error) {
+	if token == "" {
+		return nil, errTokenNotFound
+	}
+	db, err := s.database()
+	if err != nil {
+		return nil, err
+	}
+	return findToken(db, token)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test tokens management
+func TestTokens(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	token, err := addToken(db, "helpdesk", []string{"ovh"}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !strings.HasPrefix(token, tokenPrefix) {
+		t.Errorf("Expected %s prefixed token, but got: %s", tokenPrefix, token)
+	}
+	if _, err := addToken(db, "helpdesk", nil, nil); err == nil {
+		t.Errorf("Expected error for duplicated token name")
+	}
+
+	// Only hash is stored
+	var stored string
+	db.QueryRow("SELECT hash FROM api_tokens WHERE name='helpdesk'").Scan(&stored)
+	if stored != hashToken(token) || strings.Contains(stored, token) {
+		t.Errorf("Expected token hash stored, but got: %s", stored)
+	}
+
+	found, err := findToken(db, token)
+	if err != nil || found.Name != "helpdesk" || strings.Join(found.ISPs, ",") != "ovh" || len(found.Accounts) != 0 {
+		t.Errorf("Expected helpdesk token, but got: %+v %v", found, err)
+	}
+	if _, err := findToken(db, token+"x"); err != errTokenNotFound {
+		t.Errorf("Expected token not found, but got: %v", err)
+	}
+
+	tokens, err := listTokens(db)
+	if err != nil || len(tokens) != 1 || tokens[0].Name != "helpdesk" {
+		t.Errorf("Expected helpdesk token listed, but got: %+v %v", tokens, err)
+	}
+
+	if err := revokeToken(db, "helpdesk"); err != nil {
+		t.Errorf("Expected no error, but got: %v", err)
+	}
+	if err := revokeToken(db, "helpdesk"); err != errTokenNotFound {
+		t.Errorf("Expected token not found, but got: %v", err)
+	}
+	if _, err := findToken(db, token); err != errTokenNotFound {
+		t.Errorf("Expected revoked token not found, but got: %v", err)
+	}
+}
+
+// Test API authentication, tokens restrictions and access log
+func TestAPIAuth(t *testing.T) {
+	db := withTestDb(t)
+	fullToken, _ := addToken(db, "full", nil, nil)
+	ovhToken, _ := addToken(db, "ovhOnly", []string{"ovh"}, nil)
+	accountToken, _ := addToken(db, "otherAccount", nil, []string{"otherRealId"})
+
+	server := newAPIServer(dbFile)
+	defer server.Close()
+	var accessLog bytes.Buffer
+	server.accessLog = newLogger(&accessLog, logJSON, slog.LevelInfo)
+	handler := server.handler()
+
+	request := func(target string, header, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		if header == "Authorization" {
+			token = "Bearer " + token
+		}
+		if header != "" {
+			r.Header.Set(header, token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request("/domains/example.com", "", ""); w.Code != http.StatusUnauthorized || strings.Contains(w.Body.String(), "realId") {
+		t.Errorf("Expected unauthorized request without token, but got: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("/domains/example.com", "Authorization", "ds_invalid"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized request with invalid token, but got: %d", w.Code)
+	}
+	if w := request("/health", "", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected public health endpoint, but got: %d", w.Code)
+	}
+
+	if w := request("/domains/example.com", "Authorization", fullToken); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "otherRealId") || !strings.Contains(w.Body.String(), `"realId":"realId"`) {
+		t.Errorf("Expected both holders for full token, but got: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("/domains/example.com", "X-API-Key", ovhToken); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "otherRealId") {
+		t.Errorf("Expected only ovh holder, but got: %d %s", w.Code, w.Body.String())
+	}
+	// Domains held by other accounts are not found
+	if w := request("/domains/example.net", "X-API-Key", ovhToken); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "otherRealId") {
+		t.Errorf("Expected example.net not found for ovh token, but got: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("/search?q=example", "X-API-Key", ovhToken); w.Code != http.StatusOK || strings.Count(w.Body.String(), `"domain":`) != 1 {
+		t.Errorf("Expected only ovh search results, but got: %d %s", w.Code, w.Body.String())
+	}
+	if w := request("/accounts", "X-API-Key", accountToken); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "ovh") || !strings.Contains(w.Body.String(), "otherRealId") {
+		t.Errorf("Expected only otherRealId account, but got: %d %s", w.Code, w.Body.String())
+	}
+
+	// Sync status by accounts visible through realId and ISP variants
+	if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("2", "otherRealId", "cloudflare-dns", "example.org")`); err != nil {
+		t.Fatal(err)
+	}
+	run := syncRun{Started: time.Now(), Finished: time.Now(), Replaced: true}
+	if err := saveSyncResults(db, run, []accountSync{{Provider: "ovh", Account: "1", Domains: 1}, {Provider: "cloudflare", Account: "2", Domains: 3}, {Provider: "dondominio"}}); err != nil {
+		t.Fatal(err)
+	}
+	var status statusResponse
+	w := request("/status", "X-API-Key", accountToken)
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected status, but got: %d %s", w.Code, w.Body.String())
+	}
+	if len(status.Syncs) != 1 || status.Syncs[0].Provider != "cloudflare" || status.Syncs[0].Account != "2" || status.Domains != 3 {
+		t.Errorf("Expected only cloudflare account sync status, but got: %+v", status)
+	}
+
+	log := accessLog.String()
+	if !strings.Contains(log, `"token":"ovhOnly","remote":"192.0.2.1:1234","method":"GET","path":"/domains/example.net","query":"","status":404`) {
+		t.Errorf("Expected ovhOnly lookup in access log, but got: %s", log)
+	}
+	if !strings.Contains(log, `"token":"-","remote":"192.0.2.1:1234","method":"GET","path":"/domains/example.com","query":"","status":401`) {
+		t.Errorf("Expected unauthorized lookup in access log, but got: %s", log)
+	}
+	for _, token := range []string{fullToken, ovhToken, accountToken} {
+		if strings.Contains(log, token) {
+			t.Errorf("Expected no tokens in access log, but got: %s", log)
+		}
+	}
+}
+
+// Test regenerateDb keeps API tokens
+func TestRegenerateDbKeepsTokens(t *testing.T) {
+	db := withTestDb(t)
+	token, _ := addToken(db, "helpdesk", nil, nil)
+
+	populateOvhOri := populateOvh
+	populateCloudFlareOri := populateCloudFlare
+	populateGoDaddyOri := populateGoDaddy
+	populateDonDominioOri := populateDonDominio
+	defer func() {
+		populateOvh = populateOvhOri
+		populateCloudFlare = populateCloudFlareOri
+		populateGoDaddy = populateGoDaddyOri
+		populateDonDominio = populateDonDominioOri
+	}()
+	populateOvh = func(db *sql.DB) error {
+		_, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain, endpoint) VALUES ("1", "realId", "ovh", "example.org", "ovh-ca")`)
+		return err
+	}
+	populateCloudFlare = func(db *sql.DB) error {
+		return nil
+	}
+	populateGoDaddy = func(db *sql.DB) error {
+		return nil
+	}
+	populateDonDominio = func(db *sql.DB) error {
+		return nil
+	}
+
+	if err := regenerateDb(dbFile); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	records, err := lookupDomain(db, "example.org")
+	if err != nil || len(records) != 1 || records[0].Endpoint != "ovh-ca" {
+		t.Errorf("Expected regenerated example.org, but got: %+v %v", records, err)
+	}
+	if records, _ := lookupDomain(db, "example.com"); len(records) != 0 {
+		t.Errorf("Expected previous domains removed, but got: %+v", records)
+	}
+	if found, err := findToken(db, token); err != nil || found.Name != "helpdesk" {
+		t.Errorf("Expected token kept, but got: %+v %v", found, err)
+	}
+}
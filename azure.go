@@ -0,0 +1,131 @@
+package main
+
+// Azure DNS public and private zones, application client credentials are exchanged for an access token
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Credentials file in configuration directory, one per line: azureId:tenantId:clientId:clientSecret:subscription[,subscription...]
+var azureFile = "azure.list"
+
+var (
+	azureLoginEndpoint      = "https://login.microsoftonline.com"
+	azureManagementEndpoint = "https://management.azure.com"
+)
+
+// Zone resource types and their API versions, private zones are flagged by azure-private ISP
+var azureZoneTypes = []struct {
+	resource   string
+	apiVersion string
+	isp        string
+}{
+	{"Microsoft.Network/dnszones", "2018-05-01", "azure"},
+	{"Microsoft.Network/privateDnsZones", "2020-06-01", "azure-private"},
+}
+
+var getAzureToken = func(client *http.Client, tenant, clientId, clientSecret string) (string, error) {
+	return fetchOAuthToken(client, azureLoginEndpoint+"/"+url.PathEscape(tenant)+"/oauth2/v2.0/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientId},
+		"client_secret": {clientSecret},
+		"scope":         {azureManagementEndpoint + "/.default"},
+	})
+}
+
+// Get subscription zones of given resource type following next links
+var getAzureZones = func(client *http.Client, token, subscription, resource, apiVersion string) ([]string, error) {
+	zones := []string{}
+	apiUrl := azureManagementEndpoint + "/subscriptions/" + url.PathEscape(subscription) + "/providers/" + resource + "?api-version=" + apiVersion
+	for apiUrl != "" {
+		r, err := http.NewRequest(http.MethodGet, apiUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		var response struct {
+			Value []struct {
+				Name string `json:"name"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := getProviderJSON(client, r, &response); err != nil {
+			return nil, err
+		}
+		for _, zone := range response.Value {
+			zones = append(zones, zone.Name)
+		}
+		apiUrl = response.NextLink
+	}
+	return zones, nil
+}
+
+var populateAzure = func(db *sql.DB) error {
+	log := logger.With("provider", "azure")
+	accounts, err := readAccountsFile(configPath(azureFile), 5, 5)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "syntax", "azureId:tenantId:clientId:clientSecret:subscription[,subscription...]", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting Azure DNS data:")
+	for _, dataFields := range accounts {
+		azureId, tenant, clientId, clientSecret := dataFields[0], dataFields[1], dataFields[2], dataFields[3]
+		subscriptions := strings.Split(dataFields[4], ",")
+		render.Info("-- azureId: %s", azureId)
+		log := providerLogger("azure", azureId)
+
+		client, err := newProviderClient("azure", azureId)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("azure", azureId, 0, err)
+			continue
+		}
+		token, err := getAzureToken(client, tenant, clientId, clientSecret)
+		if err != nil {
+			log.Error("Unable to get access token", "operation", "login", "error", err)
+			recordAccountSync("azure", azureId, 0, err)
+			continue
+		}
+
+		// Subscription is used as realId
+		records := []domainRecord{}
+	subscriptions:
+		for _, subscription := range subscriptions {
+			for _, zoneType := range azureZoneTypes {
+				var zones []string
+				if zones, err = getAzureZones(client, token, subscription, zoneType.resource, zoneType.apiVersion); err != nil {
+					log.Error("Unable to list zones", "operation", "list_zones", "subscription", subscription, "resource", zoneType.resource, "error", err)
+					break subscriptions
+				}
+				for _, zone := range zones {
+					domain := strings.ToLower(strings.TrimSuffix(zone, "."))
+					if err := checkDNS(domain); err != nil {
+						log.Warn("Skipping invalid zone name", "operation", "list_zones", "subscription", subscription, "zone", zone, "error", err)
+						continue
+					}
+					records = append(records, domainRecord{ID: azureId, RealID: subscription, ISP: zoneType.isp, Domain: domain})
+				}
+			}
+		}
+		if err != nil {
+			recordAccountSync("azure", azureId, 0, err)
+			continue
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert zones", "operation", "insert", "error", err)
+			recordAccountSync("azure", azureId, 0, err)
+			continue
+		}
+		recordAccountSync("azure", azureId, len(records), nil)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test client credentials, next links and private zones against a local Azure stand-in
+func TestPopulateAzure(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/tenant1/oauth2/v2.0/token":
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_id") != "client1" || r.FormValue("client_secret") != "secret1" {
+				http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token_type":"Bearer","expires_in":3599,"access_token":"azureToken"}`)
+		case r.Header.Get("Authorization") != "Bearer azureToken":
+			http.Error(w, `{"error":{"code":"AuthenticationFailed"}}`, http.StatusUnauthorized)
+		case r.URL.Path == "/subscriptions/sub1/providers/Microsoft.Network/dnszones" && r.URL.Query().Get("$skipToken") == "":
+			fmt.Fprintf(w, `{"value":[{"name":"example.com","properties":{"zoneType":"Public"}}],"nextLink":"%s/subscriptions/sub1/providers/Microsoft.Network/dnszones?api-version=2018-05-01&$skipToken=page2"}`, server.URL)
+		case r.URL.Path == "/subscriptions/sub1/providers/Microsoft.Network/dnszones":
+			fmt.Fprint(w, `{"value":[{"name":"example.org","properties":{"zoneType":"Public"}}]}`)
+		case r.URL.Path == "/subscriptions/sub1/providers/Microsoft.Network/privateDnsZones" && r.URL.Query().Get("api-version") == "2020-06-01":
+			fmt.Fprint(w, `{"value":[{"name":"internal.example.com"}]}`)
+		case r.URL.Path == "/subscriptions/sub2/providers/Microsoft.Network/dnszones", r.URL.Path == "/subscriptions/sub2/providers/Microsoft.Network/privateDnsZones":
+			fmt.Fprint(w, `{"value":[]}`)
+		default:
+			http.Error(w, `{"error":{"code":"SubscriptionNotFound"}}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	azureLoginEndpointOri := azureLoginEndpoint
+	azureManagementEndpointOri := azureManagementEndpoint
+	defer func() {
+		azureLoginEndpoint = azureLoginEndpointOri
+		azureManagementEndpoint = azureManagementEndpointOri
+	}()
+	azureLoginEndpoint = server.URL
+	azureManagementEndpoint = server.URL
+
+	withTestConfig(t, azureFile, "#azureId:tenantId:clientId:clientSecret:subscription[,subscription...]\ncorp:tenant1:client1:secret1:sub1,sub2\nother:tenant1:client1:secret1:sub3\nbadSecret:tenant1:client1:wrong:sub1\n")
+	syncResults = nil
+	records, err := populateTestDb(t, populateAzure)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Expected 3 zones, but got: %+v %v", records, err)
+	}
+	for i, expected := range []domainRecord{
+		{ID: "corp", RealID: "sub1", ISP: "azure", Domain: "example.com"},
+		{ID: "corp", RealID: "sub1", ISP: "azure", Domain: "example.org"},
+		{ID: "corp", RealID: "sub1", ISP: "azure-private", Domain: "internal.example.com"},
+	} {
+		if records[i] != expected {
+			t.Errorf("Expected %+v, but got: %+v", expected, records[i])
+		}
+	}
+	if failed := failedAccounts(); len(failed) != 2 || failed[0].Account != "other" || failed[1].Account != "badSecret" {
+		t.Errorf("Expected other and badSecret accounts failed, but got: %+v", failed)
+	}
+}
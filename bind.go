@@ -0,0 +1,147 @@
+package main
+
+// Self-hosted authoritative servers zones, read from BIND zone files directories: a git repository checkout for example
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Zones directories file in configuration directory, one per line: bindId:isp:zonesDirectory
+// isp defaults to bind, relative directories are relative to configuration directory
+var bindFile = "bind.list"
+
+var errNoSOA = errors.New("No SOA record, not a zone file")
+
+// Guess zone origin from file names as db.example.com, example.com.zone or example.com.db,
+// it is used by zone files lacking $ORIGIN for @ and relative names
+func zoneFileOrigin(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	name = strings.TrimPrefix(name, "db.")
+	for _, suffix := range []string{".zone", ".db", ".hosts"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	if checkDNS(name) != nil {
+		return "."
+	}
+	return dns.Fqdn(name)
+}
+
+// Parse zone file returning zone name, taken from its SOA record. The whole file is parsed so invalid ones are skipped
+func parseZoneFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	parser := dns.NewZoneParser(f, zoneFileOrigin(path), path)
+	zone := ""
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		if _, soa := rr.(*dns.SOA); soa && zone == "" {
+			zone = strings.ToLower(strings.TrimSuffix(rr.Header().Name, "."))
+		}
+	}
+	if err := parser.Err(); err != nil {
+		return "", err
+	}
+	if zone == "" {
+		return "", errNoSOA
+	}
+	if err := checkDNS(zone); err != nil {
+		return "", err
+	}
+	return zone, nil
+}
+
+// Get zones names in directory and its subdirectories, files failing to parse are logged and skipped
+func readZoneDir(dir string, log *slog.Logger) ([]string, error) {
+	zones := []string{}
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Hidden files and directories as .git
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Dynamic zones journals
+		if !entry.Type().IsRegular() || filepath.Ext(path) == ".jnl" {
+			return nil
+		}
+
+		zone, err := parseZoneFile(path)
+		if errors.Is(err, errNoSOA) {
+			log.Debug("Skipping file", "operation", "parse_zone", "file", path, "error", err)
+			return nil
+		}
+		if err != nil {
+			log.Warn("Unable to parse zone file, skipping it", "operation", "parse_zone", "file", path, "error", err)
+			return nil
+		}
+		if previous, ok := files[zone]; ok {
+			log.Warn("Zone defined in several files, skipping it", "operation", "parse_zone", "zone", zone, "file", path, "previous", previous)
+			return nil
+		}
+		files[zone] = path
+		zones = append(zones, zone)
+		return nil
+	})
+	return zones, err
+}
+
+var populateBind = func(db *sql.DB) error {
+	log := logger.With("provider", "bind")
+	accounts, err := readAccountsFile(configPath(bindFile), 3, 3)
+	if err != nil {
+		log.Error("Unable to read zones directories file", "operation", "read_config", "syntax", "bindId:isp:zonesDirectory", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting BIND zones:")
+	for _, dataFields := range accounts {
+		bindId, isp, dir := dataFields[0], dataFields[1], dataFields[2]
+		if isp == "" {
+			isp = "bind"
+		}
+		if !filepath.IsAbs(dir) {
+			dir = configPath(dir)
+		}
+		render.Info("-- bindId: %s", bindId)
+		// Accounts are recorded by ISP, so failed ones keep their previous zones
+		log := providerLogger(isp, bindId)
+
+		zones, err := readZoneDir(dir, log)
+		if err != nil {
+			log.Error("Unable to read zones directory", "operation", "list_zones", "dir", dir, "error", err)
+			recordAccountSync(isp, bindId, 0, err)
+			continue
+		}
+		records := []domainRecord{}
+		for _, zone := range zones {
+			records = append(records, domainRecord{ID: bindId, RealID: bindId, ISP: isp, Domain: zone})
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert zones", "operation", "insert", "error", err)
+			recordAccountSync(isp, bindId, 0, err)
+			continue
+		}
+		recordAccountSync(isp, bindId, len(records), nil)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test zones are read from zone files with and without $ORIGIN, other files are skipped
+func TestPopulateBind(t *testing.T) {
+	zonesDir := t.TempDir()
+	for name, content := range map[string]string{
+		"example.com.zone":        "$ORIGIN example.com.\n$TTL 3600\n@ IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600\n@ IN NS ns1\n@ IN NS ns2.example.net.\nns1 IN A 192.0.2.1\nwww IN CNAME @\n",
+		"internal/db.Example.org": "$TTL 3600\n@ IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600\n@ IN NS ns1.example.com.\n",
+		"README":                  "Zones managed by ops team\n",
+		"broken.zone":             "$ORIGIN broken.com.\n@ IN SOA ns1\n",
+		"example.com.zone.jnl":    "binary journal",
+		".git/example.net.zone":   "$ORIGIN example.net.\n@ IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 3600\n",
+	} {
+		file := filepath.Join(zonesDir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	zone, err := parseZoneFile(filepath.Join(zonesDir, "example.com.zone"))
+	if err != nil || zone != "example.com" {
+		t.Errorf("Expected example.com zone, but got: %s %v", zone, err)
+	}
+
+	withTestConfig(t, bindFile, "#bindId:isp:zonesDirectory\nops::"+zonesDir+"\n")
+	records, err := populateTestDb(t, populateBind)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 zones, but got: %+v %v", records, err)
+	}
+	for i, domain := range []string{"example.com", "example.org"} {
+		if records[i].Domain != domain || records[i].ISP != "bind" || records[i].ID != "ops" || records[i].RealID != "ops" {
+			t.Errorf("Unexpected %s record: %+v", domain, records[i])
+		}
+	}
+
+	// Missing directory fails the account, not the provider
+	withTestConfig(t, bindFile, "ops:ns-internal:missing\n")
+	if _, err := populateTestDb(t, populateBind); err != nil {
+		t.Errorf("Expected no error with missing directory, but got: %v", err)
+	}
+
+	// Provider is skipped without zones directories file
+	withTestConfig(t, "ovh.list", "")
+	if _, err := populateTestDb(t, populateBind); err != nil {
+		t.Errorf("Expected no error without %s, but got: %v", bindFile, err)
+	}
+	withTestConfig(t, bindFile, "ops\n")
+	if _, err := populateTestDb(t, populateBind); err == nil {
+		t.Errorf("Expected error with invalid %s", bindFile)
+	}
+}
@@ -0,0 +1,912 @@
+package main
+
+// CLI subcommands
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Process exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// search command exit codes, grep alike, so scripts can branch on lookup result: errors never use exitError
+const (
+	exitFound         = exitOK
+	exitNotFound      = 1
+	exitInvalid       = exitUsage
+	exitSearchError   = 3
+	exitFoundStale    = 4
+	exitNotFoundStale = 5
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+// Filled in init, commands reference usage() that prints this table
+var commands []command
+
+func init() {
+	commands = []command{
+		{"search", "Search domains, compact output unless -details is used", cmdSearch},
+		{"sync", "Regenerate DB querying all providers", cmdSync},
+		{"shell", "Interactive search prompt, default command", cmdShell},
+		{"audit", "Report domains held by several accounts and invalid cached names", cmdAudit},
+		{"export", "Export domain inventory as CSV, JSON or XLSX", cmdExport},
+		{"import", "Import manually maintained domains from CSV/YAML files", cmdImport},
+		{"history", "Show domain changes recorded by syncs", cmdHistory},
+		{"diff", "Show domains added, removed or moved between accounts since a time", cmdDiff},
+		{"creds", "Check providers credentials files without showing secrets", cmdCreds},
+		{"serve", "HTTP REST API server and web UI for lookups", cmdServe},
+		{"daemon", "serve command syncing providers on schedule", cmdDaemon},
+		{"token", "Manage serve command API tokens", cmdToken},
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: domainSearcher [command] [flags] [args]")
+	fmt.Fprintln(w, "       domainSearcher DOMAIN       -> shortcut for: domainSearcher search DOMAIN")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.description)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Use domainSearcher COMMAND -h for command flags, all commands accept:")
+	fmt.Fprintln(w, "  -db FILE      DB file, default $DOMAINSEARCHER_DB or $XDG_DATA_HOME/domainSearcher/domain_list.db")
+	fmt.Fprintln(w, "  -config DIR   Configuration directory, default $DOMAINSEARCHER_CONFIG or $XDG_CONFIG_HOME/domainSearcher")
+	fmt.Fprintln(w, "  -no-color     Disable colors, also disabled when output is not a terminal or NO_COLOR is set")
+	fmt.Fprintln(w, "  -quiet        Only show results, warnings and errors, no banner nor screen clearing")
+	fmt.Fprintln(w, "  -verbose      Log providers HTTP requests/responses summaries, secrets are redacted")
+	fmt.Fprintln(w, "  -log-format   Log format written to stderr: text or json")
+	fmt.Fprintln(w, "Exit codes: 0 OK, 1 error, 2 invalid usage.")
+	fmt.Fprintln(w, "search exit codes: 0 found, 1 not found, 2 invalid domain/usage, 3 DB or configuration error, 4 found in stale DB, 5 not found in stale DB.")
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		return cmdShell(args)
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return exitOK
+	}
+	for _, c := range commands {
+		if args[0] == c.name {
+			return c.run(args[1:])
+		}
+	}
+
+	// Previous versions flags: -regenerateDB, -exit, -socks5 are shell ones, unless followed by domains
+	if strings.HasPrefix(args[0], "-") && !hasShellPositionalArgs(args) {
+		return cmdShell(args)
+	}
+	// domainSearcher DOMAIN shortcut
+	return cmdSearch(args)
+}
+
+// Common flags values, applied by parseFlags
+var dbFlag, configFlag, logFormatFlag string
+var noColorFlag, quietFlag, verboseFlag bool
+
+func newFlagSet(name, arguments, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&dbFlag, "db", "", "DB file, default $DOMAINSEARCHER_DB or $XDG_DATA_HOME/domainSearcher/domain_list.db.")
+	fs.StringVar(&configFlag, "config", "", "Configuration directory, default $DOMAINSEARCHER_CONFIG or $XDG_CONFIG_HOME/domainSearcher.")
+	fs.BoolVar(&noColorFlag, "no-color", false, "Disable colors, also disabled when output is not a terminal or NO_COLOR is set.")
+	fs.BoolVar(&quietFlag, "quiet", false, "Only show results, warnings and errors, no banner nor screen clearing.")
+	fs.BoolVar(&verboseFlag, "verbose", false, "Log providers HTTP requests/responses summaries, secrets are redacted.")
+	fs.StringVar(&logFormatFlag, "log-format", logText, "Log format written to stderr: text or json.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: domainSearcher %s %s\n\n%s\n\nFlags:\n", name, arguments, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Parse flags allowing them after positional arguments: search example.com -details
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	render = newRenderer(noColorFlag, quietFlag)
+	if err := checkLogFormat(logFormatFlag); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return nil, err
+	}
+	logger = newLogger(os.Stderr, logFormatFlag, logLevel(verboseFlag, quietFlag))
+	if err := resolvePaths(dbFlag, configFlag); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return nil, err
+	}
+	return positional, nil
+}
+
+// Convert flags parsing error to exit code
+func flagsExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	var pathError *os.PathError
+	if errors.As(err, &pathError) {
+		return exitError
+	}
+	return exitUsage
+}
+
+// Register -max-age flag, also used by queryDB warnings
+func addMaxAgeFlag(fs *flag.FlagSet) {
+	fs.DurationVar(&maxDataAge, "max-age", 7*24*time.Hour, "Data age since last sync from which it is considered stale, 0 disables the check.")
+}
+
+// Register -expiring-days flag, used by sync notifications
+func addExpiringFlag(fs *flag.FlagSet) {
+	fs.IntVar(&expiringDays, "expiring-days", 30, "Notify domains expiring in less days, see notifications.list.")
+}
+
+// Providers whose APIs are only reached from authorized IPs, -socks5 flag proxies them
+var ipWhitelistedProviders = []string{"dondominio", "namecheap"}
+
+// Register proxy flags, returned function loads proxies configuration once flags are parsed
+func addProxyFlags(fs *flag.FlagSet) func() error {
+	socks5Ptr := fs.String("socks5", "", "Use socks5 proxy only for IP whitelisted APIs: DonDominio and Namecheap.")
+	proxyPtr := fs.String("proxy", "", "Use socks5://[user:pass@]host:port or http://[user:pass@]host:port proxy for all providers and WHOIS lacking a more specific "+proxyFile+" rule in configuration directory.")
+
+	return func() error {
+		var err error
+		if proxies, err = loadProxies(configPath(proxyFile)); err != nil {
+			return err
+		}
+		if *proxyPtr != "" {
+			if _, err := parseProxyURL(*proxyPtr); err != nil {
+				return err
+			}
+			proxies["*"] = *proxyPtr
+		}
+		if *socks5Ptr != "" {
+			for _, provider := range ipWhitelistedProviders {
+				proxies[provider] = *socks5Ptr
+			}
+		}
+		return nil
+	}
+}
+
+func cmdSearch(args []string) int {
+	fs := newFlagSet("search", "[flags] DOMAIN...", "Search domains in DB, DB is created/populated when required.\nExit codes: 0 found, 1 not found, 2 invalid domain/usage, 3 DB or configuration error, 4 found in stale DB, 5 not found in stale DB.\nWith several domains the worst result is returned: 3 > 2 > 1 > 0, stale DB turns 0 into 4 and 1 into 5.")
+	detailsPtr := fs.Bool("details", false, "Show full domain details, NS servers and WHOIS info for domains not found.")
+	addMaxAgeFlag(fs)
+	loadProxies := addProxyFlags(fs)
+	domains, err := parseFlags(fs, args)
+	if err != nil {
+		if code := flagsExitCode(err); code != exitError {
+			return code
+		}
+		return exitSearchError
+	}
+	if len(domains) == 0 {
+		fs.Usage()
+		return exitInvalid
+	}
+	if err := loadProxies(); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitSearchError
+	}
+
+	sqliteDatabase, err := openDB(dbFile, false, false)
+	if err != nil {
+		return exitSearchError
+	}
+	defer sqliteDatabase.Close()
+
+	cliDomain := 1
+	if *detailsPtr {
+		cliDomain = 0
+	}
+
+	exitCode := exitFound
+	for _, domainToSearch := range domains {
+		if len(domains) > 1 {
+			render.Text("%s:", domainToSearch)
+		}
+		found, err := searchDomain(domainToSearch, sqliteDatabase, cliDomain)
+		exitCode = worstSearchExitCode(exitCode, searchExitCode(found, err))
+	}
+
+	// Stale DB results can't be trusted:
+	if exitCode == exitFound || exitCode == exitNotFound {
+		if stale, age := checkStaleDb(sqliteDatabase, dbFile, maxDataAge); stale {
+			// Already shown by queryDB with -details
+			if !*detailsPtr {
+				render.Warn("  WARNING: DB is %s old, consider running: domainSearcher sync", age.Round(time.Minute))
+			}
+			exitCode = map[int]int{exitFound: exitFoundStale, exitNotFound: exitNotFoundStale}[exitCode]
+		}
+	}
+	return exitCode
+}
+
+// Get search exit code for a domain lookup result
+func searchExitCode(found bool, err error) int {
+	switch {
+	case errors.Is(err, errInvalidDomain):
+		return exitInvalid
+	case err != nil:
+		return exitSearchError
+	case found:
+		return exitFound
+	default:
+		return exitNotFound
+	}
+}
+
+// Get worst of two search exit codes: DB or configuration error > invalid > not found > found
+func worstSearchExitCode(a, b int) int {
+	severity := map[int]int{exitFound: 0, exitNotFound: 1, exitInvalid: 2, exitSearchError: 3}
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+func cmdSync(args []string) int {
+	fs := newFlagSet("sync", "[flags]", "Regenerate DB querying all providers.")
+	addExpiringFlag(fs)
+	metricsFilePtr := fs.String("metrics-file", "", "Write Prometheus metrics to file after sync, for node_exporter textfile collector: use a .prom file in its directory.")
+	loadProxies := addProxyFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+	if err := loadProxies(); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+
+	render.Info("> Regenerating DB.")
+	exitCode := exitOK
+	if err := regenerateDb(dbFile); err != nil {
+		exitCode = exitError
+	}
+	// Failed syncs metrics are written too, they are the ones worth alerting on
+	if *metricsFilePtr != "" && checkFileExists(dbFile) {
+		if err := writeMetricsFile(dbFile, *metricsFilePtr); err != nil {
+			logger.Error("Unable to write metrics file", "operation", "metrics", "file", *metricsFilePtr, "error", err)
+			exitCode = exitError
+		}
+	}
+	return exitCode
+}
+
+func newShellFlagSet() (*flag.FlagSet, *bool, *bool, func() error) {
+	fs := newFlagSet("shell", "[flags]", "Interactive search prompt, DB is created/populated when required.")
+	// -regenerateDB command:
+	regenerateDBPtr := fs.Bool("regenerateDB", false, "Force DB regeneration.")
+	exitPtr := fs.Bool("exit", false, "Exit without waiting for user input, useful combined with -regenerateDB. Also useful for unit-testing.")
+	addMaxAgeFlag(fs)
+	loadProxies := addProxyFlags(fs)
+	return fs, regenerateDBPtr, exitPtr, loadProxies
+}
+
+// Check whether arguments left after shell flags, as in: domainSearcher -socks5 host:port example.com
+func hasShellPositionalArgs(args []string) bool {
+	fs, _, _, _ := newShellFlagSet()
+	fs.SetOutput(io.Discard)
+	return fs.Parse(args) == nil && fs.NArg() > 0
+}
+
+func cmdShell(args []string) int {
+	fs, regenerateDBPtr, exitPtr, loadProxies := newShellFlagSet()
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+	//fmt.Println("regenerateDB:", *regenerateDBPtr)
+	//fmt.Println("exit:", *exitPtr)
+
+	render.Banner()
+	render.Info("> DB: %s", dbFile)
+	render.Info("> Configuration: %s", configDir)
+
+	if err := loadProxies(); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+
+	sqliteDatabase, err := openDB(dbFile, *regenerateDBPtr, true)
+	if err != nil {
+		return exitError
+	}
+	defer sqliteDatabase.Close()
+
+	// Exit arg
+	if *exitPtr {
+		return exitOK
+	}
+
+	searchCLI(sqliteDatabase, false, os.Stdin)
+	return exitOK
+}
+
+func cmdAudit(args []string) int {
+	fs := newFlagSet("audit", "", "Report domains held by several accounts or ISPs and cached names with invalid syntax.")
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+
+	sqliteDatabase, err := openDB(dbFile, false, false)
+	if err != nil {
+		return exitError
+	}
+	defer sqliteDatabase.Close()
+
+	rows, err := sqliteDatabase.Query("SELECT domain, isp, realId, status FROM domain_list ORDER BY domain, isp, realId")
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	defer rows.Close()
+
+	holders := make(map[string][]string)
+	var domains []string
+	for rows.Next() {
+		var record domainRecord
+		if err := rows.Scan(&record.Domain, &record.ISP, &record.RealID, &record.Status); err != nil {
+			render.Error("++ ERROR: %s", err)
+			return exitError
+		}
+		domain := record.Domain
+		if _, ok := holders[domain]; !ok {
+			domains = append(domains, domain)
+			holders[domain] = nil
+		}
+		// Transferred out domains previous holders are not duplicates
+		if !record.held() {
+			continue
+		}
+		holder := record.ISP + " / " + record.RealID
+		if len(holders[domain]) == 0 || holders[domain][len(holders[domain])-1] != holder {
+			holders[domain] = append(holders[domain], holder)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+
+	render.Text("> Domains held by several accounts:")
+	found := 0
+	for _, domain := range domains {
+		if len(holders[domain]) > 1 {
+			render.Warn("  %s: %s", domain, strings.Join(holders[domain], ", "))
+			found++
+		}
+	}
+	render.Text("  %d found", found)
+
+	render.Text("> Cached domains with invalid syntax:")
+	found = 0
+	for _, domain := range domains {
+		if err := checkDNS(domain); err != nil {
+			render.Warn("  %s: %s", domain, err)
+			found++
+		}
+	}
+	render.Text("  %d found", found)
+	return exitOK
+}
+
+func cmdImport(args []string) int {
+	fs := newFlagSet("import", "[flags] FILE... | -list | -remove SOURCE", "Import domains of registrars without API or client-owned accounts from CSV or YAML files, they are kept across syncs.\n"+
+		"CSV files need a domain,isp,account[,realId][,expires] header, YAML ones a list of domain, isp, account, realId and expires mappings.\n"+
+		"Each file is an import source named after it, importing it again replaces its domains.")
+	sourcePtr := fs.String("source", "", "Import source name, file name without extension by default. Only with one file.")
+	listPtr := fs.Bool("list", false, "List import sources.")
+	removePtr := fs.String("remove", "", "Remove import source domains.")
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return flagsExitCode(err)
+	}
+	switch {
+	case *listPtr && len(files) == 0 && *removePtr == "" && *sourcePtr == "":
+	case *removePtr != "" && len(files) == 0 && *sourcePtr == "":
+	case len(files) > 0 && !*listPtr && *removePtr == "" && (*sourcePtr == "" || len(files) == 1):
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	// DB is created without syncing providers, there could be only imported domains
+	sqliteDatabase, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	defer sqliteDatabase.Close()
+	if err := createTable(sqliteDatabase); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+
+	switch {
+	case *listPtr:
+		sources, err := listManualSources(sqliteDatabase)
+		if err != nil {
+			render.Error("++ ERROR: %s", err)
+			return exitError
+		}
+		render.Text("> Import sources:")
+		for _, source := range sources {
+			render.Result("  %s: %d domains, imported %s", source.Source, source.Domains, source.Imported)
+		}
+	case *removePtr != "":
+		removed, err := removeManualSource(sqliteDatabase, *removePtr)
+		if err != nil {
+			render.Error("++ ERROR: %s", err)
+			return exitError
+		}
+		if removed == 0 {
+			render.Warn("  Import source %s not found", *removePtr)
+			return exitError
+		}
+		render.Text("> %s: %d domains removed", *removePtr, removed)
+	default:
+		// All files are validated before importing any of them
+		imports := make([][]manualDomain, len(files))
+		for i, file := range files {
+			if imports[i], err = loadManualFile(file); err != nil {
+				render.Error("++ ERROR: %s", err)
+				return exitError
+			}
+		}
+		for i, file := range files {
+			source := manualSourceName(file)
+			if *sourcePtr != "" {
+				source = *sourcePtr
+			}
+			if err := importManualDomains(sqliteDatabase, source, imports[i]); err != nil {
+				render.Error("++ ERROR: %s", err)
+				return exitError
+			}
+			render.Text("> %s: %d domains imported", source, len(imports[i]))
+		}
+	}
+	return exitOK
+}
+
+func cmdHistory(args []string) int {
+	fs := newFlagSet("history", "DOMAIN", "Show domain added, removed and moved between accounts events recorded by syncs, oldest first.")
+	arguments, err := parseFlags(fs, args)
+	if err != nil {
+		return flagsExitCode(err)
+	}
+	if len(arguments) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	domain := strings.ToLower(strings.TrimSuffix(arguments[0], "."))
+	if len(domain) >= 100 || checkDNS(domain) != nil {
+		render.Warn("  Invalid domain")
+		return exitUsage
+	}
+
+	sqliteDatabase, err := openDB(dbFile, false, false)
+	if err != nil {
+		return exitError
+	}
+	defer sqliteDatabase.Close()
+
+	events, err := domainHistory(sqliteDatabase, domain)
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	render.Text("> %s changes:", domain)
+	for _, event := range events {
+		render.Result("  %s", event)
+	}
+	render.Text("  %d found", len(events))
+	return exitOK
+}
+
+func cmdDiff(args []string) int {
+	fs := newFlagSet("diff", "[flags]", "Show domains added, removed and moved between accounts by syncs since a time, oldest first.")
+	sincePtr := fs.String("since", "24h", "Duration as 24h or 7d, date as 2006-01-02 or RFC3339 time.")
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+	since, err := parseSince(*sincePtr, time.Now())
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitUsage
+	}
+
+	sqliteDatabase, err := openDB(dbFile, false, false)
+	if err != nil {
+		return exitError
+	}
+	defer sqliteDatabase.Close()
+
+	events, err := domainEventsSince(sqliteDatabase, since)
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	counts := map[string]int{}
+	render.Text("> Changes since %s:", since.Format(time.RFC3339))
+	for _, event := range events {
+		render.Result("  %s", event)
+		counts[event.Event]++
+	}
+	render.Text("  %d added, %d removed, %d moved", counts[eventAdded], counts[eventRemoved], counts[eventMoved])
+	return exitOK
+}
+
+func cmdExport(args []string) int {
+	fs := newFlagSet("export", "[flags]", "Export domain inventory as CSV, JSON or XLSX workbook with one sheet per ISP.")
+	outputPtr := fs.String("o", "-", "Output file, - for stdout.")
+	formatPtr := fs.String("format", "", "Output format: csv, json or xlsx, taken from output file extension by default, csv otherwise.")
+	ispPtr := fs.String("isp", "", "Comma separated ISPs to export, all of them by default.")
+	accountPtr := fs.String("account", "", "Comma separated accounts to export, id or realId, all of them by default.")
+	tldPtr := fs.String("tld", "", "Comma separated TLDs to export as com,es, all of them by default.")
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+	format, err := exportFormat(*formatPtr, *outputPtr)
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitUsage
+	}
+
+	sqliteDatabase, err := openDB(dbFile, false, false)
+	if err != nil {
+		return exitError
+	}
+	defer sqliteDatabase.Close()
+
+	records, err := exportRecords(sqliteDatabase, splitList(*ispPtr), splitList(*accountPtr), splitList(*tldPtr))
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+
+	output := io.Writer(os.Stdout)
+	if *outputPtr != "-" {
+		file, err := os.Create(*outputPtr)
+		if err != nil {
+			render.Error("++ ERROR: %s", err)
+			return exitError
+		}
+		defer file.Close()
+		output = file
+	}
+
+	if err := exportDomains(records, format, output); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// Providers credentials files, syntax and minimum fields per line
+var credentialFiles = []struct {
+	provider  string
+	file      string
+	syntax    string
+	minFields int
+	// Optional providers are skipped when their file doesn't exist
+	optional bool
+}{
+	{"ovh", "ovh.list", "ovhId:ovhKey:ovhSecret:ovhConsumer:ovhRealId[:ovhEndpoint]", 5, false},
+	{"cloudflare", "cloudflare.list", "email:password", 2, false},
+	{"godaddy", "godaddy.list", "ID:key:secret:realId[:environment]", 4, false},
+	{"dondominio", "donDominio.list", "id:user:pass", 3, false},
+	{"bind", bindFile, "bindId:isp:zonesDirectory", 3, true},
+	{"route53", route53File, "route53Id:accessKeyId:secretAccessKey[:roleArn]", 3, true},
+	{"gcp", gcpFile, "gcpId:project[,project...]:serviceAccountKeyFile", 3, true},
+	{"azure", azureFile, "azureId:tenantId:clientId:clientSecret:subscription[,subscription...]", 5, true},
+	{"namecheap", namecheapFile, "namecheapId:apiUser:apiKey:clientIp[:userName]", 4, true},
+	{"gandi", gandiFile, "gandiId:personalAccessToken[:sharingId]", 2, true},
+	{"porkbun", porkbunFile, "porkbunId:apiKey:secretApiKey", 3, true},
+	{"hetzner", hetznerFile, "hetznerId:apiToken", 2, true},
+	{"digitalocean", digitalOceanFile, "digitaloceanId:apiToken", 2, true},
+	{"linode", linodeFile, "linodeId:apiToken", 2, true},
+	{"powerdns", powerDNSFile, "serverName:apiKey:serverId:cacheRecords:apiURL", 5, true},
+	{"external", externalFile, "isp:account:timeout:command [args...]", 4, true},
+}
+
+func cmdCreds(args []string) int {
+	fs := newFlagSet("creds", "", "Check providers credentials files syntax, only account IDs are shown.")
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+
+	exitCode := exitOK
+	for _, c := range credentialFiles {
+		if c.optional && !checkFileExists(configPath(c.file)) {
+			continue
+		}
+		render.Text("- %s: %s", c.provider, configPath(c.file))
+		accounts, err := checkCredentialFile(configPath(c.file), c.minFields)
+		if err != nil {
+			render.Error("  ++ ERROR: %s", err)
+			render.Error("     Syntax: %s", c.syntax)
+			exitCode = exitError
+			continue
+		}
+		sort.Strings(accounts)
+		for _, account := range accounts {
+			if strings.HasPrefix(account, "!") {
+				render.Warn("  -- %s: expected %s", account[1:], c.syntax)
+				exitCode = exitError
+				continue
+			}
+			render.Text("  -- %s", account)
+		}
+	}
+
+	if checkFileExists(configPath(proxyFile)) {
+		render.Text("- proxies: %s", configPath(proxyFile))
+		if _, err := loadProxies(configPath(proxyFile)); err != nil {
+			render.Error("  ++ ERROR: %s", err)
+			exitCode = exitError
+		}
+	}
+	if checkFileExists(configPath(notificationsFile)) {
+		render.Text("- notifications: %s", configPath(notificationsFile))
+		if _, err := loadNotificationRules(configPath(notificationsFile)); err != nil {
+			render.Error("  ++ ERROR: %s", err)
+			exitCode = exitError
+		}
+	}
+	return exitCode
+}
+
+// Get account IDs in credentials file, invalid lines are prefixed by !
+func checkCredentialFile(file string, minFields int) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var accounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0:1] == "#" {
+			continue
+		}
+		dataFields := strings.Split(line, ":")
+		if len(dataFields) < minFields {
+			accounts = append(accounts, "!"+dataFields[0])
+			continue
+		}
+		accounts = append(accounts, dataFields[0])
+	}
+	return accounts, scanner.Err()
+}
+
+// API endpoints and authentication, shared by serve and daemon commands
+const serveDescription = `HTTP REST API server and web UI, JSON responses:
+  GET /domains         all domains
+  GET /domains/{name}  domain holders, 404 when not found, details=true also queries NS servers and WHOIS info
+  GET /search?q=       domains containing q, limit parameter defaults to 100
+  GET /accounts        providers accounts and their domains count
+  GET /status          last sync time, domains count and providers accounts sync status
+  GET /health          200 when DB is populated
+  GET /metrics         Prometheus metrics: domains per ISP/account, sync results and lookups counts/latencies
+DB is created/populated when required, DB updated by sync command is reloaded automatically.
+Requests are authenticated with Authorization: Bearer TOKEN or X-API-Key: TOKEN headers, except /health and web UI ones, see token command.`
+
+// Register serve flags, returned function creates API server once flags are parsed and its cleanup function
+func addServeFlags(fs *flag.FlagSet) (*string, func() (*apiServer, func(), error)) {
+	listenPtr := fs.String("listen", defaultListen, "Listen address, host:port.")
+	noAuthPtr := fs.Bool("no-auth", false, "Disable token authentication, any client can see which account holds each domain.")
+	accessLogPtr := fs.String("access-log", "", "Append JSON access log to file instead of logging it to stderr.")
+
+	return listenPtr, func() (*apiServer, func(), error) {
+		server := newAPIServer(dbFile)
+		server.auth = !*noAuthPtr
+		if !server.auth {
+			logger.Warn("Token authentication disabled", "operation", "serve")
+		}
+		if *accessLogPtr == "" {
+			return server, func() { server.Close() }, nil
+		}
+
+		file, err := os.OpenFile(*accessLogPtr, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			server.Close()
+			return nil, nil, err
+		}
+		server.accessLog = newLogger(file, logJSON, slog.LevelInfo)
+		return server, func() {
+			server.Close()
+			file.Close()
+		}, nil
+	}
+}
+
+func cmdServe(args []string) int {
+	fs := newFlagSet("serve", "[flags]", serveDescription)
+	listenPtr, newServer := addServeFlags(fs)
+	loadProxies := addProxyFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+	if err := loadProxies(); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+
+	sqliteDatabase, err := openDB(dbFile, false, false)
+	if err != nil {
+		return exitError
+	}
+	sqliteDatabase.Close()
+
+	server, closeServer, err := newServer()
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	defer closeServer()
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	if err := serveAPI(ctx, *listenPtr, server.handler()); err != nil {
+		logger.Error("API server failed", "operation", "serve", "listen", *listenPtr, "error", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func cmdDaemon(args []string) int {
+	fs := newFlagSet("daemon", "[flags]", "Sync providers on schedule while serving lookups, previous data is served meanwhile and accounts failing to sync keep their previous domains.\n"+serveDescription)
+	schedulePtr := fs.String("schedule", "0 */6 * * *", "Sync schedule, cron syntax: minute hour day-of-month month day-of-week, or @hourly, @daily, @weekly, @monthly. Local time.")
+	addExpiringFlag(fs)
+	listenPtr, newServer := addServeFlags(fs)
+	loadProxies := addProxyFlags(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return flagsExitCode(err)
+	}
+	syncSchedule, err := parseSchedule(*schedulePtr)
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitUsage
+	}
+	if err := loadProxies(); err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+
+	sqliteDatabase, err := openDB(dbFile, false, false)
+	if err != nil {
+		return exitError
+	}
+	sqliteDatabase.Close()
+
+	server, closeServer, err := newServer()
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	defer closeServer()
+
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		runSchedule(ctx, syncSchedule, func() {
+			logger.Info("Scheduled sync started", "operation", "sync")
+			if err := regenerateDb(dbFile); err != nil {
+				logger.Error("Scheduled sync failed", "operation", "sync", "error", err)
+				return
+			}
+			logger.Info("Scheduled sync finished", "operation", "sync")
+		})
+	}()
+
+	exitCode := exitOK
+	if err := serveAPI(ctx, *listenPtr, server.handler()); err != nil {
+		logger.Error("API server failed", "operation", "serve", "listen", *listenPtr, "error", err)
+		exitCode = exitError
+		stop()
+	}
+	// Running sync finishes before exiting, its temporary DB would be left behind otherwise
+	<-synced
+	return exitCode
+}
+
+func cmdToken(args []string) int {
+	fs := newFlagSet("token", "add NAME [-isp LIST] [-account LIST] | list | revoke NAME", "Manage serve command API tokens, DB only keeps tokens hashes so they are shown once on creation.\nRestricted tokens only see domains held by the given ISPs/accounts, accounts match id or realId.")
+	ispPtr := fs.String("isp", "", "Comma separated ISPs allowed to the new token, all of them by default.")
+	accountPtr := fs.String("account", "", "Comma separated accounts allowed to the new token, all of them by default.")
+	arguments, err := parseFlags(fs, args)
+	if err != nil {
+		return flagsExitCode(err)
+	}
+	if len(arguments) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	action, arguments := arguments[0], arguments[1:]
+	switch {
+	case action == "list" && len(arguments) == 0:
+	case (action == "add" || action == "revoke") && len(arguments) == 1:
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	sqliteDatabase, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return exitError
+	}
+	defer sqliteDatabase.Close()
+
+	switch action {
+	case "add":
+		token, err := addToken(sqliteDatabase, arguments[0], splitList(*ispPtr), splitList(*accountPtr))
+		if err != nil {
+			render.Error("++ ERROR: %s", err)
+			return exitError
+		}
+		render.Info("> Token %s created, store it now, it can't be shown again:", arguments[0])
+		render.Result("%s", token)
+	case "list":
+		tokens, err := listTokens(sqliteDatabase)
+		if err != nil {
+			render.Error("++ ERROR: %s", err)
+			return exitError
+		}
+		for _, token := range tokens {
+			isps, accounts := strings.Join(token.ISPs, ","), strings.Join(token.Accounts, ",")
+			if isps == "" {
+				isps = "*"
+			}
+			if accounts == "" {
+				accounts = "*"
+			}
+			render.Result("%s: isps: %s accounts: %s created: %s", token.Name, isps, accounts, token.Created)
+		}
+	case "revoke":
+		if err := revokeToken(sqliteDatabase, arguments[0]); err != nil {
+			render.Error("++ ERROR: %s: %s", arguments[0], err)
+			return exitError
+		}
+		render.Info("> Token %s revoked", arguments[0])
+	}
+	return exitOK
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Create test DB file with example.com domain, dbFile is restored on test end
+func withTestDb(t *testing.T) *sql.DB {
+	testDbFile := t.TempDir() + "/testDb.db"
+	dbFileOri := dbFile
+	t.Cleanup(func() {
+		dbFile = dbFileOri
+	})
+	dbFile = testDbFile
+
+	db, err := sql.Open("sqlite3", testDbFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	if err := createTable(db); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("1", "realId", "ovh", "example.com"), ("2", "otherRealId", "cloudflare", "example.com"), ("2", "otherRealId", "cloudflare", "example.net")`)
+	if err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+	return db
+}
+
+// Run command capturing its output
+func captureRun(t *testing.T, args ...string) (int, string) {
+	osStdoutOri := os.Stdout
+	osStderrOri := os.Stderr
+	colorOutputOri := color.Output
+	colorErrorOri := color.Error
+
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	os.Stderr = w
+	color.Output = w
+	color.Error = w
+
+	// Read concurrently, big outputs would fill the pipe buffer
+	outChan := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		outChan <- out
+	}()
+
+	exitCode := run(args)
+
+	w.Close()
+	os.Stdout = osStdoutOri
+	os.Stderr = osStderrOri
+	color.Output = colorOutputOri
+	color.Error = colorErrorOri
+
+	return exitCode, string(<-outChan)
+}
+
+// Test run search command and domain shortcut
+func TestRunSearch(t *testing.T) {
+	withTestDb(t)
+
+	exitCode, out := captureRun(t, "example.com")
+	if exitCode != exitOK {
+		t.Errorf("Expected exit code %d, but got: %d", exitOK, exitCode)
+	}
+	if !strings.Contains(out, "ovh / realId") || !strings.Contains(out, "cloudflare / otherRealId") {
+		t.Errorf("Expected both example.com holders in output, but got: %s", out)
+	}
+
+	// Flags after domain argument
+	exitCode, out = captureRun(t, "search", "example.net", "-details")
+	if exitCode != exitOK {
+		t.Errorf("Expected exit code %d, but got: %d", exitOK, exitCode)
+	}
+	if !strings.Contains(out, "DOMAIN: example.net") {
+		t.Errorf("Expected example.net details in output, but got: %s", out)
+	}
+
+	// Leading flag before domain shortcut
+	exitCode, out = captureRun(t, "-max-age", "0", "example.com")
+	if exitCode != exitOK {
+		t.Errorf("Expected exit code %d, but got: %d", exitOK, exitCode)
+	}
+	if !strings.Contains(out, "ovh / realId") {
+		t.Errorf("Expected example.com search output, but got: %s", out)
+	}
+
+	exitCode, _ = captureRun(t, "search", "*.example.com")
+	if exitCode != exitUsage {
+		t.Errorf("Expected exit code %d for invalid domain, but got: %d", exitUsage, exitCode)
+	}
+
+	exitCode, _ = captureRun(t, "search")
+	if exitCode != exitUsage {
+		t.Errorf("Expected exit code %d without domain, but got: %d", exitUsage, exitCode)
+	}
+
+	exitCode, _ = captureRun(t, "search", "-unknownFlag", "example.com")
+	if exitCode != exitUsage {
+		t.Errorf("Expected exit code %d for unknown flag, but got: %d", exitUsage, exitCode)
+	}
+
+	exitCode, _ = captureRun(t, "search", "-h")
+	if exitCode != exitOK {
+		t.Errorf("Expected exit code %d for help, but got: %d", exitOK, exitCode)
+	}
+}
+
+// Test search command exit codes
+func TestRunSearchExitCodes(t *testing.T) {
+	withTestDb(t)
+
+	tests := []struct {
+		args     []string
+		exitCode int
+	}{
+		{[]string{"example.com"}, exitFound},
+		{[]string{"notindb.com"}, exitNotFound},
+		{[]string{"*.example.com"}, exitInvalid},
+		{[]string{"search", "example.com", "notindb.com"}, exitNotFound},
+		{[]string{"search", "example.com", "*.example.com", "notindb.com"}, exitInvalid},
+	}
+	for _, test := range tests {
+		if exitCode, out := captureRun(t, test.args...); exitCode != test.exitCode {
+			t.Errorf("%v: expected exit code %d, but got: %d, output: %s", test.args, test.exitCode, exitCode, out)
+		}
+	}
+
+	// Stale DB
+	oldTime := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(dbFile, oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+	exitCode, out := captureRun(t, "search", "-max-age", "24h", "example.com")
+	if exitCode != exitFoundStale {
+		t.Errorf("Expected exit code %d for stale DB, but got: %d", exitFoundStale, exitCode)
+	}
+	if !strings.Contains(out, "WARNING: DB is 48h0m0s old") {
+		t.Errorf("Expected stale DB warning, but got: %s", out)
+	}
+	if exitCode, _ := captureRun(t, "search", "-max-age", "24h", "notindb.com"); exitCode != exitNotFoundStale {
+		t.Errorf("Expected exit code %d for domain not found in stale DB, but got: %d", exitNotFoundStale, exitCode)
+	}
+	if exitCode, _ := captureRun(t, "search", "-max-age", "24h", "*.example.com"); exitCode != exitInvalid {
+		t.Errorf("Expected exit code %d for invalid domain in stale DB, but got: %d", exitInvalid, exitCode)
+	}
+	if exitCode, _ := captureRun(t, "search", "-max-age", "0", "example.com"); exitCode != exitFound {
+		t.Errorf("Expected exit code %d with staleness check disabled, but got: %d", exitFound, exitCode)
+	}
+
+	// Directory as DB file
+	if exitCode, _ := captureRun(t, "search", "-db", t.TempDir(), "example.com"); exitCode != exitSearchError {
+		t.Errorf("Expected exit code %d for DB error, but got: %d", exitSearchError, exitCode)
+	}
+	// Configuration errors aren't reported as not found
+	if exitCode, _ := captureRun(t, "search", "-proxy", "ftp://localhost:21", "example.com"); exitCode != exitSearchError {
+		t.Errorf("Expected exit code %d for proxy error, but got: %d", exitSearchError, exitCode)
+	}
+}
+
+// Test audit command
+func TestRunAudit(t *testing.T) {
+	db := withTestDb(t)
+	if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("1", "realId", "ovh", "bad_name.com")`); err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	exitCode, out := captureRun(t, "audit")
+	if exitCode != exitOK {
+		t.Errorf("Expected exit code %d, but got: %d", exitOK, exitCode)
+	}
+	if !strings.Contains(out, "example.com: cloudflare / otherRealId, ovh / realId") {
+		t.Errorf("Expected example.com held by several accounts, but got: %s", out)
+	}
+	if strings.Contains(out, "example.net:") {
+		t.Errorf("Expected example.net not to be reported, but got: %s", out)
+	}
+	if !strings.Contains(out, "bad_name.com: Domain has invalid character '_'") {
+		t.Errorf("Expected bad_name.com invalid syntax, but got: %s", out)
+	}
+}
+
+// Test transferred out domains are shown but not held
+func TestTransferredOutDomains(t *testing.T) {
+	db := withTestDb(t)
+	if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain, status) VALUES ("3", "3", "godaddy", "gone.com", ?), ("3", "3", "godaddy", "example.net", ?)`, statusTransferredOut, statusTransferredOut); err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	exitCode, out := captureRun(t, "search", "gone.com")
+	if exitCode != exitNotFound || !strings.Contains(out, "godaddy / 3 (transferred out)") || !strings.Contains(out, "NOT FOUND") {
+		t.Errorf("Expected transferred out domain not found, but got: %d %s", exitCode, out)
+	}
+	if exitCode, out := captureRun(t, "search", "example.net"); exitCode != exitFound {
+		t.Errorf("Expected example.net found, but got: %d %s", exitCode, out)
+	}
+
+	if exitCode, out := captureRun(t, "audit"); exitCode != exitOK || strings.Contains(out, "example.net:") || strings.Contains(out, "godaddy") {
+		t.Errorf("Expected no transferred out duplicates, but got: %d %s", exitCode, out)
+	}
+}
+
+// Test exportCSV
+func TestExportCSV(t *testing.T) {
+	db := withTestDb(t)
+
+	records, err := exportRecords(db, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	var output bytes.Buffer
+	if err := exportCSV(records, &output); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	expected := "id,realId,isp,domain,endpoint,expires,source,status\n2,otherRealId,cloudflare,example.com,,,,\n2,otherRealId,cloudflare,example.net,,,,\n1,realId,ovh,example.com,,,,\n"
+	if output.String() != expected {
+		t.Errorf("Expected:\n%s\nbut got:\n%s", expected, output.String())
+	}
+}
+
+// Test checkCredentialFile
+func TestCheckCredentialFile(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "godaddy.list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.WriteString("#ID:key:secret:realId\ngoodId:key:secret:realId\nbadId:key\n")
+	tmpfile.Close()
+
+	accounts, err := checkCredentialFile(tmpfile.Name(), 4)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if strings.Join(accounts, ",") != "goodId,!badId" {
+		t.Errorf("Expected goodId,!badId accounts, but got: %v", accounts)
+	}
+	if strings.Contains(strings.Join(accounts, ","), "secret") {
+		t.Errorf("Expected no secrets in accounts, but got: %v", accounts)
+	}
+}
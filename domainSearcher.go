@@ -6,8 +6,8 @@ package main
 // go get github.com/inancgumus/screen
 // go get github.com/mattn/go-sqlite3
 // go get github.com/fatih/color
+// go get github.com/mattn/go-isatty
 // go get github.com/cloudflare/cloudflare-go
-// go get github.com/oze4/godaddygo
 // go get github.com/twiny/whois/v2
 // go get github.com/davecgh/go-spew/spew
 
@@ -17,7 +17,6 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -26,25 +25,18 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/chzyer/readline"
 	"github.com/cloudflare/cloudflare-go"
-	"github.com/fatih/color"
-	"github.com/inancgumus/screen"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/ovh/go-ovh/ovh"
-	"github.com/oze4/godaddygo"
 	"github.com/twiny/whois/v2"
 	"golang.org/x/net/proxy"
 	// "github.com/davecgh/go-spew/spew"
 )
 
-const (
-	Cyan  = "\033[36m"
-	Reset = "\033[0m"
-)
-
 func checkFileExists(filePath string) bool {
 	_, error := os.Stat(filePath)
 	return !errors.Is(error, os.ErrNotExist)
@@ -104,20 +96,74 @@ func checkDNS(name string) error {
 }
 
 func createTable(db *sql.DB) error {
-	// Set default font color:
-	color.Set(color.FgCyan)
-
-	createTableSQL := `CREATE TABLE IF NOT EXISTS domain_list ( "id" VARCHAR(100), "realId" VARCHAR(100), "isp" VARCHAR(100), "domain" VARCHAR(100));`
+	createTableSQL := `CREATE TABLE IF NOT EXISTS domain_list ( "id" VARCHAR(100), "realId" VARCHAR(100), "isp" VARCHAR(100), "domain" VARCHAR(100), "endpoint" VARCHAR(100) DEFAULT '', "expires" VARCHAR(30) DEFAULT '');`
 	statement, err := db.Prepare(createTableSQL)
 	if err != nil {
-		color.Red("++ ERROR: %s", err)
-		// Set default font color:
-		color.Set(color.FgCyan)
+		logger.Error("Unable to create table", "operation", "create_table", "error", err)
 		return err
 	}
 	statement.Exec()
 	//log.Println(">> domain_list table created")
 
+	// DB files created by previous versions lack the newer columns, add them:
+	if err := migrateTable(db, "domain_list", domainListColumns); err != nil {
+		logger.Error("Unable to migrate table", "operation", "migrate_table", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// expires column date format
+const expiresFormat = "2006-01-02"
+
+// Columns added to domain_list after its initial id/realId/isp/domain schema
+var domainListColumns = [][2]string{
+	{"endpoint", `VARCHAR(100) DEFAULT ''`},
+	// Registration expiry date, empty when provider doesn't report it
+	{"expires", `VARCHAR(30) DEFAULT ''`},
+	// Import source of manually maintained domains, empty for providers APIs ones
+	{"source", `VARCHAR(100) DEFAULT ''`},
+	// Registrar domain status as ACTIVE or EXPIRED, empty when provider doesn't report it
+	{"status", `VARCHAR(30) DEFAULT ''`},
+}
+
+// All domain_list columns
+func domainListColumnNames() []string {
+	names := []string{"id", "realId", "isp", "domain"}
+	for _, column := range domainListColumns {
+		names = append(names, column[0])
+	}
+	return names
+}
+
+// Add to table the columns it is missing
+func migrateTable(db *sql.DB, table string, columns [][2]string) error {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, column := range columns {
+		if existing[column[0]] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN "%s" %s`, table, column[0], column[1])); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -128,40 +174,40 @@ var getOvhDomains = func(client *ovh.Client, OVHDomainData *[]string) error {
 	return nil
 }
 
+var getOvhZones = func(client *ovh.Client, OVHZoneData *[]string) error {
+	if err := client.Get("/domain/zone", &OVHZoneData); err != nil {
+		return err
+	}
+	return nil
+}
+
 var populateOvh = func(db *sql.DB) error {
-	fmt.Println()
-	fmt.Println("- Getting OVH data:")
-	ovhIdsFile := "configs/ovh.list"
+	render.Info("")
+	render.Info("- Getting OVH data:")
+	log := logger.With("provider", "ovh")
+	ovhIdsFile := configPath("ovh.list")
 	if _, err := os.Stat(ovhIdsFile); err != nil {
-		color.Red("++ ERROR: File does not exist: %s", ovhIdsFile)
-		color.Red("   Create it with the following content syntax:")
-		color.Red("   ovhId:ovhKey:ovhSecret:ovhConsumer:ovhRealId")
-		// Set default font color:
-		color.Set(color.FgCyan)
+		log.Error("Credentials file does not exist, create it with the following content syntax", "operation", "read_config", "file", ovhIdsFile,
+			"syntax", "ovhId:ovhKey:ovhSecret:ovhConsumer:ovhRealId[:ovhEndpoint]",
+			"endpoints", "ovh-eu (default), ovh-ca, ovh-us, kimsufi-eu, kimsufi-ca, soyoustart-eu, soyoustart-ca")
 		return err
 	} else {
 		file, err := os.Open(ovhIdsFile)
 		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to open credentials file", "operation", "read_config", "file", ovhIdsFile, "error", err)
 			return err
 		}
 
-		insertSQL := `INSERT INTO domain_list(id, realId, isp, domain) VALUES (?, ?, ?, ?)`
+		insertSQL := `INSERT INTO domain_list(id, realId, isp, domain, endpoint) VALUES (?, ?, ?, ?, ?)`
 		statement, err := db.Prepare(insertSQL)
 		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to prepare insert statement", "operation", "insert", "error", err)
 			return err
 		}
 
 		// Parse IDs config file:
 		scanner := bufio.NewScanner(file)
 		for scanner.Scan() {
-			// If API access fails, color configuration is lost, reassign in each iteration
-			color.Set(color.FgCyan)
 			//fmt.Println(scanner.Text())
 			dataFields := strings.Split(scanner.Text(), ":")
 			//fmt.Println(dataFields)
@@ -174,47 +220,81 @@ var populateOvh = func(db *sql.DB) error {
 			ovhSecret := dataFields[2]
 			ovhConsumer := dataFields[3]
 			ovhRealId := dataFields[4]
-			fmt.Println("-- ovhId:", ovhId)
+			// Optional endpoint field, custom endpoint URLs contain colons so join remaining fields
+			ovhEndpoint := "ovh-eu"
+			if len(dataFields) > 5 && dataFields[5] != "" {
+				ovhEndpoint = strings.Join(dataFields[5:], ":")
+			}
+			render.Info("-- ovhId: %s endpoint: %s", ovhId, ovhEndpoint)
+			log := providerLogger("ovh", ovhId)
 			//fmt.Println("ovhKey:", ovhKey)
 			//fmt.Println("ovhSecret:", ovhSecret)
 			//fmt.Println("ovhConsumer:", ovhConsumer)
 			//fmt.Println("ovhRealId:", ovhRealId)
-			client, _ := ovh.NewClient(
-				"ovh-eu",
+			client, err := ovh.NewClient(
+				ovhEndpoint,
 				ovhKey,
 				ovhSecret,
 				ovhConsumer,
 			)
+			if err != nil {
+				log.Error("Unable to create API client", "operation", "create_client", "endpoint", ovhEndpoint, "error", err)
+				recordAccountSync("ovh", ovhId, 0, err)
+				continue
+			}
+			if client.Client, err = newHTTPClient("ovh", ovhId); err != nil {
+				log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+				recordAccountSync("ovh", ovhId, 0, err)
+				continue
+			}
 
 			// Query OVH API:
 			OVHDomainData := []string{}
 			// client.Get wrapped in order to be able to mock it
 			//if err := client.Get("/domain", &OVHDomainData); err != nil {
 			if err := getOvhDomains(client, &OVHDomainData); err != nil {
-				color.Red("++ ERROR: %s", err)
-				// Set default font color:
-				color.Set(color.FgCyan)
+				log.Error("Unable to list domains", "operation", "list_domains", "endpoint", ovhEndpoint, "error", err)
+				recordAccountSync("ovh", ovhId, 0, err)
 				continue
 			}
 			//fmt.Println("OVHDomainData: ", OVHDomainData)
 
 			// Insert retrieved information to DB:
+			registered := make(map[string]bool)
 			for i := 0; i < len(OVHDomainData); i++ {
 				//fmt.Printf("Inserting ID: %s RealID: %s, ISP: %s Domain: %s.\n", ovhId, ovhRealId, "ovh", OVHDomainData[i])
-				_, err = statement.Exec(ovhId, ovhRealId, "ovh", OVHDomainData[i])
+				_, err = statement.Exec(ovhId, ovhRealId, "ovh", OVHDomainData[i], ovhEndpoint)
+				if err != nil {
+					log.Error("Unable to insert domain", "operation", "insert", "domain", OVHDomainData[i], "error", err)
+					return err
+				}
+				registered[OVHDomainData[i]] = true
+			}
+
+			// DNS zones hosted at OVH whose domain is registered elsewhere:
+			OVHZoneData := []string{}
+			if err := getOvhZones(client, &OVHZoneData); err != nil {
+				log.Error("Unable to list DNS zones", "operation", "list_zones", "endpoint", ovhEndpoint, "error", err)
+				recordAccountSync("ovh", ovhId, 0, err)
+				continue
+			}
+			domains := len(OVHDomainData)
+			for _, zone := range OVHZoneData {
+				if registered[zone] {
+					continue
+				}
+				_, err = statement.Exec(ovhId, ovhRealId, "ovh-dns", zone, ovhEndpoint)
 				if err != nil {
-					color.Red("++ ERROR: %s", err)
-					// Set default font color:
-					color.Set(color.FgCyan)
+					log.Error("Unable to insert DNS zone", "operation", "insert", "domain", zone, "error", err)
 					return err
 				}
+				domains++
 			}
+			recordAccountSync("ovh", ovhId, domains, nil)
 		}
 
 		if err := scanner.Err(); err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to read credentials file", "operation", "read_config", "file", ovhIdsFile, "error", err)
 			return err
 		}
 	}
@@ -230,39 +310,31 @@ var getCloudFlareDomains = func(api *cloudflare.API) ([]cloudflare.Zone, error)
 }
 
 var populateCloudFlare = func(db *sql.DB) error {
-	fmt.Println()
-	fmt.Println("- Getting Cloudflare data:")
-	cloudflareIdsFile := "configs/cloudflare.list"
+	render.Info("")
+	render.Info("- Getting Cloudflare data:")
+	log := logger.With("provider", "cloudflare")
+	cloudflareIdsFile := configPath("cloudflare.list")
 	if _, err := os.Stat(cloudflareIdsFile); err != nil {
-		color.Red("++ ERROR: File does not exist: %s", cloudflareIdsFile)
-		color.Red("   Create it with the following content syntax:")
-		color.Red("   email:password")
-		// Set default font color:
-		color.Set(color.FgCyan)
+		log.Error("Credentials file does not exist, create it with the following content syntax", "operation", "read_config", "file", cloudflareIdsFile,
+			"syntax", "email:password")
 		return err
 	} else {
 		file, err := os.Open(cloudflareIdsFile)
 		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to open credentials file", "operation", "read_config", "file", cloudflareIdsFile, "error", err)
 			return err
 		}
 
 		insertSQL := `INSERT INTO domain_list(id, realId, isp, domain) VALUES (?, ?, ?, ?)`
 		statement, err := db.Prepare(insertSQL)
 		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to prepare insert statement", "operation", "insert", "error", err)
 			return err
 		}
 
 		// Parse IDs config file:
 		scanner := bufio.NewScanner(file)
 		for scanner.Scan() {
-			// If API access fails, color configuration is lost, reassign in each iteration
-			color.Set(color.FgCyan)
 			//fmt.Println(scanner.Text())
 			dataFields := strings.Split(scanner.Text(), ":")
 			//fmt.Println(dataFields)
@@ -272,14 +344,21 @@ var populateCloudFlare = func(db *sql.DB) error {
 				continue
 			}
 			cloudflareApiKey := dataFields[1]
-			fmt.Println("-- cloudflareEmail:", cloudflareEmail)
+			render.Info("-- cloudflareEmail: %s", cloudflareEmail)
+			log := providerLogger("cloudflare", cloudflareEmail)
 			//fmt.Println("cloudflareApiKey:", cloudflareApiKey)
 
-			api, err := cloudflare.New(cloudflareApiKey, cloudflareEmail)
+			httpClient, err := newHTTPClient("cloudflare", cloudflareEmail)
 			if err != nil {
-				color.Red("++ ERROR: %s", err)
-				// Set default font color:
-				color.Set(color.FgCyan)
+				log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+				recordAccountSync("cloudflare", cloudflareEmail, 0, err)
+				continue
+			}
+
+			api, err := cloudflare.New(cloudflareApiKey, cloudflareEmail, cloudflare.HTTPClient(httpClient))
+			if err != nil {
+				log.Error("Unable to create API client", "operation", "create_client", "error", err)
+				recordAccountSync("cloudflare", cloudflareEmail, 0, err)
 				continue
 			}
 
@@ -287,9 +366,8 @@ var populateCloudFlare = func(db *sql.DB) error {
 			//zones, err := api.ListZones(context.Background())
 			zones, err := getCloudFlareDomains(api)
 			if err != nil {
-				color.Red("++ ERROR: %s", err)
-				// Set default font color:
-				color.Set(color.FgCyan)
+				log.Error("Unable to list zones", "operation", "list_zones", "error", err)
+				recordAccountSync("cloudflare", cloudflareEmail, 0, err)
 				continue
 			}
 
@@ -297,123 +375,132 @@ var populateCloudFlare = func(db *sql.DB) error {
 				//fmt.Println(z.Name)
 				_, err = statement.Exec(cloudflareEmail, cloudflareEmail, "cloudflare", z.Name)
 				if err != nil {
-					color.Red("++ ERROR: %s", err)
-					// Set default font color:
-					color.Set(color.FgCyan)
+					log.Error("Unable to insert domain", "operation", "insert", "domain", z.Name, "error", err)
 					return err
 				}
 			}
+			recordAccountSync("cloudflare", cloudflareEmail, len(zones), nil)
 		}
 
 		if err := scanner.Err(); err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to read credentials file", "operation", "read_config", "file", cloudflareIdsFile, "error", err)
 			return err
 		}
 	}
 	return nil
 }
 
-var getGoDaddyDomains = func(api godaddygo.API) ([]godaddygo.DomainSummary, error) {
-	godaddy := api.V1()
-	zones, err := godaddy.ListDomains(context.Background())
-	if err != nil {
-		return nil, err
+// GoDaddy production and OTE (test environment) API endpoints
+var goDaddyEndpoints = map[string]string{
+	"production": "https://api.godaddy.com",
+	"ote":        "https://api.ote-godaddy.com",
+}
+
+// Domains statuses listed, transferred out ones are kept so lookups reveal where they were
+const goDaddyStatuses = "ACTIVE,EXPIRED," + statusTransferredOut
+
+// Domains per page, next page starts after marker domain
+const goDaddyPageSize = 1000
+
+type goDaddyDomain struct {
+	Domain  string    `json:"domain"`
+	Status  string    `json:"status"`
+	Expires time.Time `json:"expires"`
+}
+
+// Get customer domains, shopperId scopes reseller requests to given customer
+var getGoDaddyDomains = func(client *http.Client, endpoint, key, secret, shopperId string) ([]goDaddyDomain, error) {
+	domains := []goDaddyDomain{}
+	marker := ""
+	for {
+		query := url.Values{"statuses": {goDaddyStatuses}, "limit": {fmt.Sprint(goDaddyPageSize)}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		r, err := http.NewRequest(http.MethodGet, endpoint+"/v1/domains?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Authorization", "sso-key "+key+":"+secret)
+		if shopperId != "" {
+			r.Header.Set("X-Shopper-Id", shopperId)
+		}
+
+		var page []goDaddyDomain
+		if err := getProviderJSON(client, r, &page); err != nil {
+			return nil, err
+		}
+		domains = append(domains, page...)
+		if len(page) < goDaddyPageSize {
+			return domains, nil
+		}
+		marker = page[len(page)-1].Domain
 	}
-	//spew.Dump(zones)
-	return zones, err
 }
 
 var populateGoDaddy = func(db *sql.DB) error {
-	fmt.Println()
-	fmt.Println("- Getting GoDaddy data:")
-	godaddyIdsFile := "configs/godaddy.list"
+	render.Info("")
+	render.Info("- Getting GoDaddy data:")
+	log := logger.With("provider", "godaddy")
+	godaddyIdsFile := configPath("godaddy.list")
 	if _, err := os.Stat(godaddyIdsFile); err != nil {
-		color.Red("++ ERROR: File does not exist: %s", godaddyIdsFile)
-		color.Red("   Create it with the following content syntax:")
-		color.Red("   ID:key:secret")
-		// Set default font color:
-		color.Set(color.FgCyan)
+		log.Error("Credentials file does not exist, create it with the following content syntax", "operation", "read_config", "file", godaddyIdsFile,
+			"syntax", "ID:key:secret:realId[:environment]")
 		return err
-	} else {
-		file, err := os.Open(godaddyIdsFile)
-		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
-			return err
+	}
+	accounts, err := readAccountsFile(godaddyIdsFile, 4, 5)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "file", godaddyIdsFile, "syntax", "ID:key:secret:realId[:environment]", "error", err)
+		return err
+	}
+
+	for _, dataFields := range accounts {
+		godaddyId, godaddyKey, godaddySecret, godaddyRealId := dataFields[0], dataFields[1], dataFields[2], dataFields[3]
+		render.Info("-- godaddyId: %s", godaddyId)
+		log := providerLogger("godaddy", godaddyId)
+
+		// Production by default, ote for testing against GoDaddy test environment
+		environment := accountField(dataFields, 4)
+		if environment == "" {
+			environment = "production"
+		}
+		endpoint, ok := goDaddyEndpoints[environment]
+		if !ok {
+			err := fmt.Errorf("Invalid environment %s, use production or ote", environment)
+			log.Error("Invalid account configuration", "operation", "read_config", "error", err)
+			recordAccountSync("godaddy", godaddyId, 0, err)
+			continue
 		}
 
-		insertSQL := `INSERT INTO domain_list(id, realId, isp, domain) VALUES (?, ?, ?, ?)`
-		statement, err := db.Prepare(insertSQL)
+		httpClient, err := newProviderClient("godaddy", godaddyId)
 		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
-			return err
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("godaddy", godaddyId, 0, err)
+			continue
 		}
 
-		// Parse IDs config file:
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			// If API access fails, color configuration is lost, reassign in each iteration
-			color.Set(color.FgCyan)
-			//fmt.Println(scanner.Text())
-			dataFields := strings.Split(scanner.Text(), ":")
-			//fmt.Println(dataFields)
-			godaddyId := dataFields[0]
-			// Check comment line
-			if godaddyId[0:1] == "#" {
-				continue
-			}
-			godaddyKey := dataFields[1]
-			godaddySecret := dataFields[2]
-			godaddyRealId := dataFields[3]
-			fmt.Println("-- godaddyId:", godaddyId)
-			//fmt.Println("godaddyKey:", godaddyKey)
-			//fmt.Println("godaddySecret:", godaddySecret)
-			//fmt.Println("godaddyRealId:", godaddyRealId)
-
-			api, err := godaddygo.NewProduction(godaddyKey, godaddySecret)
-			if err != nil {
-				color.Red("++ ERROR: %s", err)
-				// Set default font color:
-				color.Set(color.FgCyan)
-				continue
-			}
-			//spew.Dump(api)
-			//godaddy := api.V1()
-
-			// Fetch all zones available to this user.
-			//zones, err := godaddy.ListDomains(context.Background())
-			zones, err := getGoDaddyDomains(api)
-			//spew.Dump(zones)
-			if err != nil {
-				color.Red("++ ERROR: %s", err)
-				// Set default font color:
-				color.Set(color.FgCyan)
-				continue
-			}
+		// realId is the customer (shopper) ID whose domains are listed
+		domains, err := getGoDaddyDomains(httpClient, endpoint, godaddyKey, godaddySecret, godaddyRealId)
+		if err != nil {
+			log.Error("Unable to list domains", "operation", "list_domains", "environment", environment, "error", err)
+			recordAccountSync("godaddy", godaddyId, 0, err)
+			continue
+		}
 
-			for _, z := range zones {
-				//fmt.Println(z.Domain)
-				_, err = statement.Exec(godaddyId, godaddyRealId, "godaddy", z.Domain)
-				if err != nil {
-					color.Red("++ ERROR: %s", err)
-					// Set default font color:
-					color.Set(color.FgCyan)
-					return err
-				}
+		records := []domainRecord{}
+		for _, domain := range domains {
+			record := domainRecord{ID: godaddyId, RealID: godaddyRealId, ISP: "godaddy", Domain: strings.ToLower(domain.Domain), Status: domain.Status}
+			if !domain.Expires.IsZero() {
+				record.Expires = domain.Expires.UTC().Format(expiresFormat)
 			}
+			records = append(records, record)
 		}
-
-		if err := scanner.Err(); err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
-			return err
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert domains", "operation", "insert", "error", err)
+			recordAccountSync("godaddy", godaddyId, 0, err)
+			continue
 		}
+		recordAccountSync("godaddy", godaddyId, len(records), nil)
 	}
 	return nil
 }
@@ -426,42 +513,34 @@ var getDonDominioDomains = func(client *http.Client, r *http.Request) (*http.Res
 	return resp, nil
 }
 
-var populateDonDominio = func(db *sql.DB, socks5 string) error {
+var populateDonDominio = func(db *sql.DB) error {
 	// curl -d "apiuser=USERNAME&apipasswd=PASSWORD" -H "Content-Type: application/x-www-form-urlencoded" -X POST https://simple-api.dondominio.net/tool/hello/|jq
-	// DonDominio requires IP-API whitelisting
-	fmt.Println()
-	fmt.Println("- Getting DonDominio data:")
-	donDominioIdsFile := "configs/donDominio.list"
+	// DonDominio requires IP-API whitelisting, use a proxy from an allowed system if required
+	render.Info("")
+	render.Info("- Getting DonDominio data:")
+	log := logger.With("provider", "dondominio")
+	donDominioIdsFile := configPath("donDominio.list")
 	if _, err := os.Stat(donDominioIdsFile); err != nil {
-		color.Red("++ ERROR: File does not exist: %s", donDominioIdsFile)
-		color.Red("   Create it with the following content syntax:")
-		color.Red("   id:user:pass")
-		// Set default font color:
-		color.Set(color.FgCyan)
+		log.Error("Credentials file does not exist, create it with the following content syntax", "operation", "read_config", "file", donDominioIdsFile,
+			"syntax", "id:user:pass")
 		return err
 	} else {
 		file, err := os.Open(donDominioIdsFile)
 		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to open credentials file", "operation", "read_config", "file", donDominioIdsFile, "error", err)
 			return err
 		}
 
-		insertSQL := `INSERT INTO domain_list(id, realId, isp, domain) VALUES (?, ?, ?, ?)`
+		insertSQL := `INSERT INTO domain_list(id, realId, isp, domain, expires) VALUES (?, ?, ?, ?, ?)`
 		statement, err := db.Prepare(insertSQL)
 		if err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to prepare insert statement", "operation", "insert", "error", err)
 			return err
 		}
 
 		// Parse IDs config file:
 		scanner := bufio.NewScanner(file)
 		for scanner.Scan() {
-			// If API access fails, color configuration is lost, reassign in each iteration
-			color.Set(color.FgCyan)
 			//fmt.Println(scanner.Text())
 			dataFields := strings.Split(scanner.Text(), ":")
 			//fmt.Println(dataFields)
@@ -473,26 +552,16 @@ var populateDonDominio = func(db *sql.DB, socks5 string) error {
 			donDominioUser := dataFields[1]
 			donDominioPass := dataFields[2]
 			//fmt.Println("donDominioId: ", donDominioId)
-			fmt.Println("-- donDominioId:", donDominioId)
+			render.Info("-- donDominioId: %s", donDominioId)
+			log := providerLogger("dondominio", donDominioId)
 			//fmt.Println("donDominioUser: ", donDominioUser)
 			//fmt.Println("donDominioPass: ", donDominioPass)
 
-			client := &http.Client{}
-			if socks5 != "nil" {
-				socks5Proxy := socks5
-				dialer, err := proxy.SOCKS5("tcp", socks5Proxy, nil, proxy.Direct)
-				if err != nil {
-					color.Red("++ ERROR: Unable to connect to SOCKS5 proxy: %v", err)
-					return err
-				}
-
-				transport := &http.Transport{
-					Dial: dialer.Dial,
-				}
-
-				client = &http.Client{
-					Transport: transport,
-				}
+			client, err := newHTTPClient("dondominio", donDominioId)
+			if err != nil {
+				log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+				recordAccountSync("dondominio", donDominioId, 0, err)
+				continue
 			}
 
 			apiUrl := "https://simple-api.dondominio.net"
@@ -513,9 +582,8 @@ var populateDonDominio = func(db *sql.DB, socks5 string) error {
 
 			if resp, err := getDonDominioDomains(client, r); err != nil {
 				//if resp, err := client.Do(r); err != nil {
-				color.Red("++ ERROR populateDonDominio: %s", err)
-				// Set default font color:
-				color.Set(color.FgCyan)
+				log.Error("Unable to list domains", "operation", "list_domains", "error", err)
+				recordAccountSync("dondominio", donDominioId, 0, err)
 				continue
 			} else {
 				// Define json structs
@@ -555,64 +623,81 @@ var populateDonDominio = func(db *sql.DB, socks5 string) error {
 				var response Response
 				err := json.Unmarshal([]byte(string(respBody)), &response)
 				if err != nil {
-					color.Red("Error deserializing JSON, continuing: %v -> %v", err, string(respBody))
+					log.Error("Unable to deserialize JSON response, continuing", "operation", "list_domains", "status", resp.StatusCode, "error", err)
+					recordAccountSync("dondominio", donDominioId, 0, err)
+					continue
+				}
+
+				if !response.Success {
+					log.Error("API error", "operation", "list_domains", "error_code", response.ErrorCode, "error", response.ErrorCodeMsg)
+					recordAccountSync("dondominio", donDominioId, 0, fmt.Errorf("API error %d: %s", response.ErrorCode, response.ErrorCodeMsg))
 					continue
 				}
 
 				for _, domain := range response.ResponseData.Domains {
 					//fmt.Println("Domain:", domain.Name)
-					_, err = statement.Exec(donDominioId, donDominioUser, "dondominio", domain.Name)
+					_, err = statement.Exec(donDominioId, donDominioUser, "dondominio", domain.Name, domain.TsExpir)
 					if err != nil {
-						color.Red("++ ERROR: %v", err)
-						// Set default font color:
-						color.Set(color.FgCyan)
+						log.Error("Unable to insert domain", "operation", "insert", "domain", domain.Name, "error", err)
 						return err
 					}
 				}
+				recordAccountSync("dondominio", donDominioId, len(response.ResponseData.Domains), nil)
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			color.Red("++ ERROR: %s", err)
-			// Set default font color:
-			color.Set(color.FgCyan)
+			log.Error("Unable to read credentials file", "operation", "read_config", "file", donDominioIdsFile, "error", err)
 			return err
 		}
 	}
 	return nil
 }
 
-func populateDB(db *sql.DB, socks5 string) error {
-	// Set default font color:
-	color.Set(color.FgCyan)
-
+// Populate db with providers domains, mainDbFile imported domains are added too
+func populateDB(db *sql.DB, mainDbFile string) error {
 	populatingError := false
 
-	fmt.Println("> Populating DB")
-
-	if err := populateOvh(db); err != nil {
-		//color.Red("++ ERROR populateOvh: %s", err)
-		populatingError = true
-	}
-
-	if err := populateCloudFlare(db); err != nil {
-		//color.Red("++ ERROR populateCloudFlare: %s", err)
-		populatingError = true
-	}
-
-	if err := populateGoDaddy(db); err != nil {
-		//color.Red("++ ERROR populateGoDaddy: %s", err)
-		populatingError = true
-	}
-
-	if err := populateDonDominio(db, socks5); err != nil {
-		//color.Red("++ ERROR populateDonDominio: %s", err)
-		populatingError = true
+	render.Info("> Populating DB")
+	syncResults = nil
+
+	for _, provider := range []struct {
+		name     string
+		populate func(*sql.DB) error
+	}{
+		{"ovh", populateOvh},
+		{"cloudflare", populateCloudFlare},
+		{"godaddy", populateGoDaddy},
+		{"dondominio", populateDonDominio},
+		{"bind", populateBind},
+		{"route53", populateRoute53},
+		{"gcp", populateGCP},
+		{"azure", populateAzure},
+		{"namecheap", populateNamecheap},
+		{"gandi", populateGandi},
+		{"porkbun", populatePorkbun},
+		{"hetzner", populateHetzner},
+		{"digitalocean", populateDigitalOcean},
+		{"linode", populateLinode},
+		{"powerdns", populatePowerDNS},
+		{"external", populateExternal},
+		{manualProvider, func(db *sql.DB) error { return populateManual(db, mainDbFile) }},
+	} {
+		accounts := len(syncResults)
+		err := provider.populate(db)
+		if err != nil {
+			logger.Error("Provider failed", "provider", provider.name, "operation", "populate", "error", err)
+			populatingError = true
+		}
+		// Provider result, its accounts ones are recorded by populate function
+		domains := 0
+		for _, result := range syncResults[accounts:] {
+			domains += result.Domains
+		}
+		recordAccountSync(provider.name, "", domains, err)
 	}
 
-	// If API access fails, color configuration is lost
-	color.Set(color.FgCyan)
-	fmt.Println("> Done")
+	render.Info("> Done")
 
 	if populatingError {
 		return fmt.Errorf("Error populating DB")
@@ -627,7 +712,15 @@ var getDnsNs = func(domainToSearch string) ([]*net.NS, error) {
 
 var getWhois = func(domainToSearch string) (whois.Response, error) {
 	var whoisResponse whois.Response
-	if client, err := whois.NewClient(nil); err != nil {
+	var dialer proxy.Dialer
+	if u, err := parseProxyURL(proxies.lookup("whois", "")); err != nil {
+		return whoisResponse, err
+	} else if u != nil {
+		if dialer, err = newProxyDialer(u); err != nil {
+			return whoisResponse, err
+		}
+	}
+	if client, err := whois.NewClient(dialer); err != nil {
 		return whoisResponse, err
 	} else {
 		resp, err := client.Query(context.TODO(), domainToSearch)
@@ -635,115 +728,166 @@ var getWhois = func(domainToSearch string) (whois.Response, error) {
 	}
 }
 
-func queryDB(domainToSearch string, db *sql.DB, cliDomain int) error {
-	// Set default font color:
-	color.Set(color.FgCyan)
+// Domain holder account
+type domainRecord struct {
+	ID       string `json:"id"`
+	RealID   string `json:"realId"`
+	ISP      string `json:"isp"`
+	Domain   string `json:"domain"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Status   string `json:"status,omitempty"`
+}
+
+// Domains transferred to other registrars are kept but not held by their accounts anymore
+const statusTransferredOut = "TRANSFERRED_OUT"
+
+// domain_list rows condition excluding transferred out domains
+const heldDomainsWhere = "status != '" + statusTransferredOut + "'"
+
+func (r domainRecord) held() bool {
+	return r.Status != statusTransferredOut
+}
+
+// Get domain holders accounts, empty when domain is not in DB
+func lookupDomain(db *sql.DB, domainToSearch string) ([]domainRecord, error) {
+	rows, err := db.Query("SELECT "+domainRecordColumns+" FROM domain_list WHERE domain=?", domainToSearch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDomainRecords(rows)
+}
+
+// domainRecord columns
+const domainRecordColumns = "id, realId, isp, domain, endpoint, expires, source, status"
+
+// rows columns: domainRecordColumns
+func scanDomainRecords(rows *sql.Rows) ([]domainRecord, error) {
+	records := []domainRecord{}
+	for rows.Next() {
+		var record domainRecord
+		if err := rows.Scan(&record.ID, &record.RealID, &record.ISP, &record.Domain, &record.Endpoint, &record.Expires, &record.Source, &record.Status); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
 
+// Query domain in DB, found is false when domain is not in DB
+func queryDB(domainToSearch string, db *sql.DB, cliDomain int) (bool, error) {
 	//fmt.Println("domainToSearch: ", domainToSearch)
 
-	// Check if domain related row exists:
-	row, err := db.Query("SELECT COUNT(*) FROM domain_list WHERE domain=?", domainToSearch)
+	records, err := lookupDomain(db, domainToSearch)
 	if err != nil {
-		color.Red("++ ERROR: %s", err)
-		// Set default font color:
-		color.Set(color.FgCyan)
-		return err
+		render.Error("++ ERROR: %s", err)
+		return false, err
 	}
-	defer row.Close()
 
-	for row.Next() {
-		var n int
-		row.Scan(&n)
-		//fmt.Printf("N: %d\n", n)
-		if n == 0 {
-			color.Yellow("  NOT FOUND")
+	// Compact output warns once for all searched domains, see cmdSearch
+	if cliDomain == 0 {
+		if stale, age := checkStaleDb(db, dbFile, maxDataAge); stale {
+			render.Warn("  WARNING: DB is %s old, consider running: domainSearcher sync", age.Round(time.Minute))
+		}
+	}
 
-			if cliDomain == 0 {
-				// NS lookup:
-				ns, err := getDnsNs(domainToSearch)
-				if err != nil {
-					color.Red("++ ERROR NS: Couldnt query NS servers: %s", err)
-					// Set default font color:
-					color.Set(color.FgCyan)
-				} else {
-					color.Set(color.FgCyan)
-					fmt.Println("------------")
-					color.Yellow("  NS servers:")
-					for _, v := range ns {
-						color.Set(color.FgGreen)
-						fmt.Println("  ", v.Host)
-					}
-				}
+	if len(records) == 0 {
+		render.Warn("  NOT FOUND")
 
-				// WHOIS lookup
-				resp, err := getWhois(domainToSearch)
-				if err != nil {
-					color.Red("++ ERROR WHOIS: %s", err)
-					// Set default font color:
-					color.Set(color.FgCyan)
-				} else {
-					// Print the response
-					color.Set(color.FgCyan)
-					fmt.Println("------------")
-					color.Yellow("  WHOIS Info:")
-					color.Set(color.FgGreen)
-					fmt.Printf("%+v\n", resp)
-					color.Set(color.FgCyan)
-					fmt.Println("------------")
+		if cliDomain == 0 {
+			// NS lookup:
+			ns, err := getDnsNs(domainToSearch)
+			if err != nil {
+				render.Error("++ ERROR NS: Couldnt query NS servers: %s", err)
+			} else {
+				render.Text("------------")
+				render.Warn("  NS servers:")
+				for _, v := range ns {
+					render.Result("   %s", v.Host)
 				}
+			}
 
-				fmt.Println("")
+			// WHOIS lookup
+			resp, err := getWhois(domainToSearch)
+			if err != nil {
+				render.Error("++ ERROR WHOIS: %s", err)
+			} else {
+				// Print the response
+				render.Text("------------")
+				render.Warn("  WHOIS Info:")
+				render.Result("%+v", resp)
+				render.Text("------------")
 			}
-			return nil
-		}
-	}
 
-	// Query DB for domain data:
-	row, err = db.Query("SELECT * FROM domain_list WHERE domain=?", domainToSearch)
-	if err != nil {
-		color.Red("++ ERROR: %s", err)
-		// Set default font color:
-		color.Set(color.FgCyan)
-		return err
+			render.Text("")
+		}
+		return false, nil
 	}
-	defer row.Close()
 
 	if cliDomain == 0 {
-		fmt.Println("------------")
+		render.Text("------------")
 	}
-	for row.Next() {
-		var id string
-		var realId string
-		var isp string
-		var domain string
-		row.Scan(&id, &realId, &isp, &domain)
+	held := 0
+	for _, record := range records {
+		if record.held() {
+			held++
+		}
 		if cliDomain == 0 {
-			color.Green("  ID: %s\n", id)
-			color.Green("  REALID: %s\n", realId)
-			color.Green("  ISP: %s\n", isp)
-			color.Green("  DOMAIN: %s\n", domain)
-			color.Set(color.FgCyan)
-			fmt.Println("------------")
+			render.Result("  ID: %s", record.ID)
+			render.Result("  REALID: %s", record.RealID)
+			render.Result("  ISP: %s", record.ISP)
+			if record.Endpoint != "" {
+				render.Result("  ENDPOINT: %s", record.Endpoint)
+			}
+			render.Result("  DOMAIN: %s", record.Domain)
+			if record.Expires != "" {
+				render.Result("  EXPIRES: %s", record.Expires)
+			}
+			if record.Status != "" {
+				render.Result("  STATUS: %s", record.Status)
+			}
+			if record.Source != "" {
+				render.Result("  SOURCE: %s, manually maintained", record.Source)
+			}
+			cached, err := cachedRecords(db, record)
+			if err != nil {
+				render.Error("++ ERROR: Couldnt read cached records: %s", err)
+			}
+			if len(cached) > 0 {
+				render.Result("  RECORDS:")
+				for _, cachedRecord := range cached {
+					render.Result("   %s %d %s %s", cachedRecord.Name, cachedRecord.TTL, cachedRecord.Type, cachedRecord.Content)
+				}
+			}
+			render.Text("------------")
+		} else if record.Source != "" {
+			render.Result("%s / %s (manual: %s)", record.ISP, record.RealID, record.Source)
+		} else if !record.held() {
+			render.Result("%s / %s (transferred out)", record.ISP, record.RealID)
 		} else {
-			color.Green("%s / %s\n", isp, realId)
+			render.Result("%s / %s", record.ISP, record.RealID)
+		}
+		// Previous sync data is kept for failed accounts, it could be outdated
+		if lastError := lastSyncError(db, record); lastError != "" {
+			render.Warn("  WARNING: %s / %s failed in last sync, data could be outdated: %s", record.ISP, record.RealID, lastError)
 		}
 	}
 
+	if held == 0 {
+		render.Warn("  NOT FOUND: transferred out from listed accounts")
+	}
 	if cliDomain == 0 {
-		fmt.Println("")
+		render.Text("")
 	}
-	return nil
+	return held > 0, nil
 }
 
 var checkPopulatedDb = func(db *sql.DB) error {
-	// Set default font color:
-	color.Set(color.FgCyan)
-
 	row, err := db.Query("SELECT COUNT(*) FROM domain_list")
 	if err != nil {
-		color.Red("++ ERROR: %s", err)
-		// Set default font color:
-		color.Set(color.FgCyan)
+		logger.Error("Unable to count domains", "operation", "check_db", "error", err)
 		return err
 	}
 	defer row.Close()
@@ -759,248 +903,251 @@ var checkPopulatedDb = func(db *sql.DB) error {
 	return nil
 }
 
-func regenerateDb(dbFile, socks5 string) error {
+// DB is generated in a temporary file whose domain_list replaces dbFile one once populated,
+// so concurrent executions, cron ones for example, keep querying previous DB meanwhile.
+// Other dbFile tables, API tokens for example, are kept
+func regenerateDb(dbFile string) error {
 	//fmt.Println("Executing: regenerateDb")
+	tmpDbFile := dbFile + ".tmp"
+	run := syncRun{Started: time.Now()}
 
-	// Remove DB:
-	err := os.Remove(dbFile)
-	fileNotFoundError := "remove " + dbFile + ": no such file or directory"
-	if err != nil && err.Error() != fileNotFoundError {
-		color.Red("++ ERROR: %s", err)
-		// Set default font color:
-		color.Set(color.FgCyan)
+	// Remove previous failed regeneration DB:
+	err := os.Remove(tmpDbFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error("Unable to remove temporary DB", "operation", "regenerate_db", "db", tmpDbFile, "error", err)
 		return err
 	}
+	// Temporary DB is removed on return, it only survives killed processes and it's never read
+	defer os.Remove(tmpDbFile)
 
 	// Create DB:
-	file, err := os.Create(dbFile)
+	file, err := os.OpenFile(tmpDbFile, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
 	if err != nil {
-		color.Red("++ ERROR: %s", err)
-		// Set default font color:
-		color.Set(color.FgCyan)
+		logger.Error("Unable to create temporary DB", "operation", "regenerate_db", "db", tmpDbFile, "error", err)
 		return err
 	} else {
-		fmt.Println("> DB file created successfully")
+		render.Info("> DB file created successfully")
 	}
 	file.Close()
 
 	// Open DB:
 	var sqliteDatabase *sql.DB
-	if sqliteDatabase, err = sql.Open("sqlite3", dbFile); err != nil {
-		color.Red("++ ERROR: regenerateDb Error opening DB file: %s", err)
-		// Set default font color:
-		color.Set(color.FgCyan)
+	if sqliteDatabase, err = sql.Open("sqlite3", tmpDbFile); err != nil {
+		logger.Error("Unable to open DB", "operation", "regenerate_db", "db", tmpDbFile, "error", err)
 		return err
 	}
 	defer sqliteDatabase.Close()
 
 	// Create table:
 	if err := createTable(sqliteDatabase); err != nil {
-		color.Red("++ ERROR creating DB table")
-		// Set default font color:
-		color.Set(color.FgCyan)
+		logger.Error("Unable to create DB table", "operation", "regenerate_db", "db", tmpDbFile, "error", err)
 		return err
 	} else {
-		fmt.Println("> DB table created successfully")
+		render.Info("> DB table created successfully")
 	}
 
-	// Populate DB:
-	if err := populateDB(sqliteDatabase, socks5); err != nil {
-		color.Red("++ ERROR populating DB")
-		// Set default font color:
-		color.Set(color.FgCyan)
+	// Populate DB, errors in some providers still populate the DB with the remaining ones data:
+	populateErr := populateDB(sqliteDatabase, dbFile)
+	// Sync run and last attempt/success per provider and account, saved once dbFile is replaced
+	defer func() {
+		run.Finished = time.Now()
+		saveSyncResultsFile(dbFile, run)
+	}()
+	if populateErr != nil {
+		logger.Error("Some providers failed, DB populated with the remaining ones", "operation", "regenerate_db", "error", populateErr)
+	}
+	if err := checkPopulatedDb(sqliteDatabase); err != nil {
+		logger.Error("Empty DB or not populated correctly, keeping previous DB", "operation", "regenerate_db", "db", dbFile, "error", err)
 		return err
+	}
+	sqliteDatabase.Close()
+
+	// Replace DB:
+	var events []domainEvent
+	if checkFileExists(dbFile) {
+		events, err = replaceDomainList(dbFile, tmpDbFile, failedAccounts())
 	} else {
-		if err := checkPopulatedDb(sqliteDatabase); err != nil {
-			color.Red("++ ERROR: Empty DB or not populated correctly")
-			// Set default font color:
-			color.Set(color.FgCyan)
-			return err
-		} else {
-			fmt.Println("> DB populated successfully")
-		}
+		err = os.Rename(tmpDbFile, dbFile)
+	}
+	if err != nil {
+		logger.Error("Unable to replace DB", "operation", "regenerate_db", "db", dbFile, "error", err)
+		return err
+	}
+	run.Replaced = true
+	notifySync(dbFile, events)
+	if populateErr != nil {
+		return populateErr
 	}
+	render.Info("> DB populated successfully")
 	return nil
 }
 
-func searchCLI(db *sql.DB, oneSearch bool, rIn io.ReadCloser) {
-	//fmt.Printf("len(os.Args): %v\n", len(os.Args))
-	if len(os.Args) == 2 {
-		domainToSearch := os.Args[1]
-		// Check correct domain syntax
-		//fmt.Printf("domainToSearch: %s\n", domainToSearch)
-		//fmt.Printf("len(domainToSearch): %i\n", len(domainToSearch))
-		if domainToSearch != "" {
-			if len(domainToSearch) < 100 {
-				if err := checkDNS(domainToSearch); err != nil {
-					//fmt.Printf("err: %v\n", err)
-					color.Yellow("  Invalid domain")
-				} else {
-					//fmt.Printf("err: %v\n", err)
-					if err := queryDB(domainToSearch, db, 1); err != nil {
-						color.Red("++ ERROR: %s", err)
-						// Set default font color:
-						color.Set(color.FgCyan)
-					}
-				}
-				domainToSearch = ""
-			} else {
-				color.Yellow("  Invalid domain")
-			}
+// Copy tmpDbFile domain_list to dbFile in a single transaction recording domain changes, failed accounts keep their dbFile domains.
+// Recorded changes are returned
+func replaceDomainList(dbFile, tmpDbFile string, failed []accountSync) ([]domainEvent, error) {
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Previous versions DB files lack the newer columns
+	if err := createTable(db); err != nil {
+		return nil, err
+	}
+
+	// Attached databases are per connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS regenerated", tmpDbFile); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE regenerated")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	columns := strings.Join(domainListColumnNames(), ", ")
+	for _, account := range failed {
+		// Partially retrieved domains are replaced by the previous ones
+		where, args := domainListRows(account)
+		if _, err := tx.Exec("DELETE FROM regenerated.domain_list WHERE "+where, args...); err != nil {
+			return nil, err
 		}
-	} else {
-		// Search domain:
-		fmt.Println("")
-		// When executed from CLI readline reads straightaway from terminal nos os.Stdin, for that reason we need to pass a STDIN when executed from tests
-		rl, err := readline.NewEx(&readline.Config{
-			Prompt: Cyan + "> Domain to search: " + Reset,
-			Stdin:  rIn,
-		})
+		result, err := tx.Exec("INSERT INTO regenerated.domain_list ("+columns+") SELECT "+columns+" FROM main.domain_list WHERE "+where, args...)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		defer rl.Close()
-
-		for {
-			// Fix: Strange behaviour related to colors when returning from queryDB function, this color reassigment fixes it
-			color.Set(color.FgCyan)
+		kept, _ := result.RowsAffected()
+		logger.Warn("Account sync failed, keeping its previous domains", "provider", account.Provider, "account", account.Account, "operation", "regenerate_db", "domains", kept)
+	}
+	events, err := recordDomainEvents(tx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	render.Info("> %d domain changes since previous sync", len(events))
+	if _, err := tx.Exec("DELETE FROM main.domain_list"); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT INTO main.domain_list (" + columns + ") SELECT " + columns + " FROM regenerated.domain_list"); err != nil {
+		return nil, err
+	}
+	if err := replaceCachedRecords(tx, failed); err != nil {
+		return nil, err
+	}
+	return events, tx.Commit()
+}
 
-			// Read user input
-			line, err := rl.Readline()
-			if err != nil {
-				break
-			}
-			color.Set(color.FgCyan)
-
-			domainToSearch := strings.TrimSpace(line)
-
-			// Check correct domain syntax
-			//fmt.Printf("domainToSearch: %s\n", domainToSearch)
-			//fmt.Printf("len(domainToSearch): %i\n", len(domainToSearch))
-			if domainToSearch != "" {
-				if len(domainToSearch) < 100 {
-					if err := checkDNS(domainToSearch); err != nil {
-						//fmt.Printf("err: %v\n", err)
-						color.Yellow("  Invalid domain")
-					} else {
-						//fmt.Printf("err: %v\n", err)
-						if err := queryDB(domainToSearch, db, 0); err != nil {
-							color.Red("++ ERROR: %s", err)
-							// Set default font color:
-							color.Set(color.FgCyan)
-						}
-					}
-					domainToSearch = ""
-				} else {
-					color.Yellow("  Invalid domain")
-				}
-			}
-			if oneSearch {
-				return
-			}
-		}
+// Validate domain syntax and query it
+func searchDomain(domainToSearch string, db *sql.DB, cliDomain int) (bool, error) {
+	// Check correct domain syntax
+	//fmt.Printf("domainToSearch: %s\n", domainToSearch)
+	//fmt.Printf("len(domainToSearch): %i\n", len(domainToSearch))
+	if len(domainToSearch) >= 100 {
+		render.Warn("  Invalid domain")
+		return false, errInvalidDomain
+	}
+	if err := checkDNS(domainToSearch); err != nil {
+		//fmt.Printf("err: %v\n", err)
+		render.Warn("  Invalid domain")
+		return false, errInvalidDomain
+	}
+	found, err := queryDB(domainToSearch, db, cliDomain)
+	if err != nil {
+		render.Error("++ ERROR: %s", err)
+		return false, err
 	}
+	return found, nil
 }
 
-func main() {
-	dbFile := "domain_list.db"
-
-	// Set default font color:
-	color.Set(color.FgCyan)
-
-	//fmt.Print("\033[H\033[2J")
-	// Portable clear screen version
-	screen.MoveTopLeft()
-	screen.Clear()
-	//fmt.Printf("len(os.Args): %d\n", len(os.Args))
-	//for i, arg := range os.Args {
-	//	fmt.Printf("Argument %d: %s\n", i, arg)
-	//}
-	// cmd is used by unit tests
-	if len(os.Args) != 2 || os.Args[0] == "cmd" {
-		fmt.Println("######################################################################################")
-		fmt.Println("| OVH-Cloudflare-GoDaddy-DonDominio(SOCKS-5) NS/Whois search system: Ctrl+c -> Exit  |")
-		fmt.Printf("| v0.9-sqlite-cli: %s - coded by Kr0m: alfaexploit.com                   |\n", dbFile)
-		fmt.Println("######################################################################################")
-		fmt.Println("")
-	}
-
-	// -regenerateDB command:
-	regenerateDBPtr := flag.Bool("regenerateDB", false, "Force DB regeneration.")
-	// -socks5 command:
-	socks5Ptr := flag.String("socks5", "", "Use socks5 proxy only for DonDominio scraping.")
-	exitPtr := flag.Bool("exit", false, "Exit without waiting for user input, useful combined with -regenerateDB. Also useful for unit-testing.")
-	flag.Parse()
-	//fmt.Println("regenerateDB:", *regenerateDBPtr)
-	//fmt.Println("socks5:", *socks5Ptr)
-	//fmt.Println("exit:", *exitPtr)
-
-	socks5 := "nil"
-	if *socks5Ptr != "" {
-		socks5 = *socks5Ptr
-	}
-
-	if len(os.Args) != 2 {
-		fmt.Printf("> Checking if previous %s file exists\n", dbFile)
-	}
-	sqliteBbExists := checkFileExists(dbFile)
-	if sqliteBbExists {
-		if len(os.Args) != 2 {
-			fmt.Printf("  DB: %s FOUND\n", dbFile)
-		}
-		// Regenerate DB arg:
-		if *regenerateDBPtr {
-			fmt.Println("> Regenerating DB.")
-			if err := regenerateDb(dbFile, socks5); err != nil {
-				os.Exit(1)
-			}
+var errInvalidDomain = errors.New("Invalid domain")
 
-			// Exit arg
-			if *exitPtr {
-				return
-			}
-		} else {
-			// Open DB:
-			var sqliteDatabase *sql.DB
-			var err error
-			if sqliteDatabase, err = sql.Open("sqlite3", dbFile); err != nil {
-				color.Red("++ ERROR: main Error opening DB file: %s", err)
-				os.Exit(1)
-			}
-			defer sqliteDatabase.Close()
+func searchCLI(db *sql.DB, oneSearch bool, rIn io.ReadCloser) {
+	// Search domain:
+	render.Info("")
+	// When executed from CLI readline reads straightaway from terminal nos os.Stdin, for that reason we need to pass a STDIN when executed from tests
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt: render.Prompt("> Domain to search: "),
+		Stdin:  rIn,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer rl.Close()
 
-			// Check if DB is populated
-			if err := checkPopulatedDb(sqliteDatabase); err != nil {
-				fmt.Println("> DB is not populated")
-				if err := regenerateDb(dbFile, socks5); err != nil {
-					os.Exit(1)
-				}
-			}
+	for {
+		// Read user input
+		line, err := rl.Readline()
+		if err != nil {
+			break
+		}
 
-			// Exit arg
-			if *exitPtr {
-				return
-			}
+		domainToSearch := strings.TrimSpace(line)
+		if domainToSearch != "" {
+			searchDomain(domainToSearch, db, 0)
 		}
-	} else {
-		fmt.Printf("  DB: %s file NOT FOUND, creating it\n", dbFile)
-		if err := regenerateDb(dbFile, socks5); err != nil {
-			os.Exit(1)
+		if oneSearch {
+			return
 		}
+	}
+}
 
-		// Exit arg
-		if *exitPtr {
-			return
+// Open DB, creating and populating it when not found or empty, or when regeneration is requested
+func openDB(dbFile string, regenerate, verbose bool) (*sql.DB, error) {
+	if verbose {
+		render.Info("> Checking if previous %s file exists", dbFile)
+	}
+	if checkFileExists(dbFile) {
+		if verbose {
+			render.Info("  DB: %s FOUND", dbFile)
+		}
+		if regenerate {
+			render.Info("> Regenerating DB.")
+			if err := regenerateDb(dbFile); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		render.Info("  DB: %s file NOT FOUND, creating it", dbFile)
+		if err := regenerateDb(dbFile); err != nil {
+			return nil, err
 		}
 	}
 
 	// Open DB:
-	var sqliteDatabase *sql.DB
-	var err error
-	if sqliteDatabase, err = sql.Open("sqlite3", dbFile); err != nil {
-		color.Red("++ ERROR: main2 Error opening DB file: %s", err)
-		os.Exit(1)
+	sqliteDatabase, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "open_db", "db", dbFile, "error", err)
+		return nil, err
 	}
-	defer sqliteDatabase.Close()
-	searchCLI(sqliteDatabase, false, os.Stdin)
+
+	// Bring DB files created by previous versions up to date:
+	if err := createTable(sqliteDatabase); err != nil {
+		sqliteDatabase.Close()
+		return nil, err
+	}
+
+	// Check if DB is populated
+	if err := checkPopulatedDb(sqliteDatabase); err != nil {
+		render.Info("> DB is not populated")
+		sqliteDatabase.Close()
+		if err := regenerateDb(dbFile); err != nil {
+			return nil, err
+		}
+		if sqliteDatabase, err = sql.Open("sqlite3", dbFile); err != nil {
+			logger.Error("Unable to open DB", "operation", "open_db", "db", dbFile, "error", err)
+			return nil, err
+		}
+	}
+	return sqliteDatabase, nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
@@ -10,8 +10,10 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -21,7 +23,6 @@ import (
 	"github.com/fatih/color"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/ovh/go-ovh/ovh"
-	"github.com/oze4/godaddygo"
 	"github.com/twiny/whois/v2"
 )
 
@@ -114,18 +115,40 @@ func TestCreateTable(t *testing.T) {
 	}
 }
 
+// Use a temporary configuration directory containing name file with given content
+func withTestConfig(t *testing.T, name, content string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/"+name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	configDirOri := configDir
+	t.Cleanup(func() {
+		configDir = configDirOri
+	})
+	configDir = dir
+}
+
 func TestPopulateOvh(t *testing.T) {
+	withTestConfig(t, "ovh.list", "#ovhId:ovhKey:ovhSecret:ovhConsumer:ovhRealId\ntestId:key:secret:consumer:realId\ncaId:key:secret:consumer:caRealId:ovh-ca\n")
+
 	// Copy original functions content
 	getOvhDomainsOri := getOvhDomains
+	getOvhZonesOri := getOvhZones
 	// unmock functions content
 	defer func() {
 		getOvhDomains = getOvhDomainsOri
+		getOvhZones = getOvhZonesOri
 	}()
 
 	getOvhDomains = func(client *ovh.Client, OVHDomainData *[]string) error {
 		*OVHDomainData = append(*OVHDomainData, "testdomain1.com")
 		return nil
 	}
+	getOvhZones = func(client *ovh.Client, OVHZoneData *[]string) error {
+		*OVHZoneData = append(*OVHZoneData, "testdomain1.com", "zoneonly.com")
+		return nil
+	}
 
 	// Create memory database
 	db, err := sql.Open("sqlite3", ":memory:")
@@ -143,9 +166,61 @@ func TestPopulateOvh(t *testing.T) {
 	if err := populateOvh(db); err != nil {
 		t.Errorf("Expected no error when checking populateOvh, but got: %v", err)
 	}
+
+	// Check endpoint is recorded and zones are only imported when not registered at OVH
+	var endpoint string
+	if err := db.QueryRow("SELECT endpoint FROM domain_list WHERE id='caId' AND domain='testdomain1.com'").Scan(&endpoint); err != nil {
+		t.Fatalf("Expected caId testdomain1.com row, but got: %v", err)
+	}
+	if endpoint != "ovh-ca" {
+		t.Errorf("Expected endpoint ovh-ca, but got: %s", endpoint)
+	}
+	if err := db.QueryRow("SELECT endpoint FROM domain_list WHERE id='testId' AND domain='testdomain1.com'").Scan(&endpoint); err != nil {
+		t.Fatalf("Expected testId testdomain1.com row, but got: %v", err)
+	}
+	if endpoint != "ovh-eu" {
+		t.Errorf("Expected default endpoint ovh-eu, but got: %s", endpoint)
+	}
+
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM domain_list WHERE isp='ovh-dns' AND domain='zoneonly.com'").Scan(&n)
+	if n != 2 {
+		t.Errorf("Expected zoneonly.com imported as ovh-dns for both accounts, but got %d rows", n)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM domain_list WHERE isp='ovh-dns' AND domain='testdomain1.com'").Scan(&n)
+	if n != 0 {
+		t.Errorf("Expected registered testdomain1.com not to be imported as ovh-dns, but got %d rows", n)
+	}
+}
+
+// Test createTable adds missing columns to previous versions DB files
+func TestCreateTableMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE domain_list ( "id" VARCHAR(100), "realId" VARCHAR(100), "isp" VARCHAR(100), "domain" VARCHAR(100));`); err != nil {
+		t.Fatalf("Failed to create old table: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("1", "realId", "ovh", "example.com")`); err != nil {
+		t.Fatalf("Failed to insert domain: %v", err)
+	}
+
+	if err := createTable(db); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	var endpoint string
+	if err := db.QueryRow("SELECT endpoint FROM domain_list WHERE domain='example.com'").Scan(&endpoint); err != nil {
+		t.Errorf("Expected endpoint column to exist, but got error: %v", err)
+	}
 }
 
 func TestPopulateCloudFlare(t *testing.T) {
+	withTestConfig(t, "cloudflare.list", "#email:password\nuser@example.com:apiKey\n")
+
 	// Copy original functions content
 	getCloudFlareDomainsOri := getCloudFlareDomains
 	// unmock functions content
@@ -254,6 +329,8 @@ func TestPopulateCloudFlare(t *testing.T) {
 }
 
 func TestPopulateGoDaddy(t *testing.T) {
+	withTestConfig(t, "godaddy.list", "#ID:key:secret:realId\ntestId:key:secret:realId\n")
+
 	// Copy original functions content
 	getGoDaddyDomainsOri := getGoDaddyDomains
 	// unmock functions content
@@ -261,18 +338,16 @@ func TestPopulateGoDaddy(t *testing.T) {
 		getGoDaddyDomains = getGoDaddyDomainsOri
 	}()
 
-	getGoDaddyDomains = func(api godaddygo.API) ([]godaddygo.DomainSummary, error) {
+	getGoDaddyDomains = func(client *http.Client, endpoint, key, secret, shopperId string) ([]goDaddyDomain, error) {
 		expiration, _ := time.Parse(time.RFC3339, "2025-01-01T00:00:00Z")
-		created, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
 
-		zone := godaddygo.DomainSummary{
-			Domain:    "example.com",
-			Status:    "ACTIVE",
-			Expires:   expiration,
-			CreatedAt: created,
+		zone := goDaddyDomain{
+			Domain:  "example.com",
+			Status:  "ACTIVE",
+			Expires: expiration,
 		}
 
-		zones := []godaddygo.DomainSummary{zone}
+		zones := []goDaddyDomain{zone}
 		return zones, nil
 	}
 
@@ -294,13 +369,79 @@ func TestPopulateGoDaddy(t *testing.T) {
 	}
 }
 
+// Test OTE environment, customer scoped listing, marker pagination and statuses against a local GoDaddy stand-in
+func TestGetGoDaddyDomains(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/v1/domains" || r.Header.Get("Authorization") != "sso-key key:secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"code":"UNABLE_TO_AUTHENTICATE","message":"Unable to authenticate user"}`)
+			return
+		}
+		if r.Header.Get("X-Shopper-Id") != "customer1" || query.Get("statuses") != "ACTIVE,EXPIRED,TRANSFERRED_OUT" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"code":"ACCESS_DENIED","message":"Authenticated user is not allowed access"}`)
+			return
+		}
+		domains := []string{}
+		if query.Get("marker") == "" {
+			for i := 0; i < goDaddyPageSize; i++ {
+				domains = append(domains, fmt.Sprintf(`{"domain":"domain%04d.com","domainId":%d,"status":"ACTIVE","expires":"2030-01-01T10:00:00.000Z"}`, i, i))
+			}
+		} else if query.Get("marker") == fmt.Sprintf("domain%04d.com", goDaddyPageSize-1) {
+			domains = append(domains, `{"domain":"expired.com","status":"EXPIRED","expires":"2024-01-01T10:00:00.000Z"}`, `{"domain":"gone.com","status":"TRANSFERRED_OUT"}`)
+		}
+		fmt.Fprint(w, "["+strings.Join(domains, ",")+"]")
+	}))
+	defer server.Close()
+
+	goDaddyEndpointsOri := goDaddyEndpoints
+	defer func() {
+		goDaddyEndpoints = goDaddyEndpointsOri
+	}()
+	goDaddyEndpoints = map[string]string{"ote": server.URL}
+
+	withTestConfig(t, "godaddy.list", "#ID:key:secret:realId[:environment]\nreseller:key:secret:customer1:ote\nother:key:secret:customer2:ote\nprod:key:secret:customer1\n")
+	syncResults = nil
+	records, err := populateTestDb(t, populateGoDaddy)
+	if err != nil || len(records) != goDaddyPageSize+2 {
+		t.Fatalf("Expected %d domains, but got: %d %v", goDaddyPageSize+2, len(records), err)
+	}
+	for _, expected := range []domainRecord{
+		{ID: "reseller", RealID: "customer1", ISP: "godaddy", Domain: "domain0000.com", Expires: "2030-01-01", Status: "ACTIVE"},
+		{ID: "reseller", RealID: "customer1", ISP: "godaddy", Domain: "expired.com", Expires: "2024-01-01", Status: "EXPIRED"},
+		{ID: "reseller", RealID: "customer1", ISP: "godaddy", Domain: "gone.com", Status: "TRANSFERRED_OUT"},
+	} {
+		found := false
+		for _, record := range records {
+			found = found || record == expected
+		}
+		if !found {
+			t.Errorf("Expected %+v in domains", expected)
+		}
+	}
+
+	// Other customers and missing environments fail alone
+	failed := failedAccounts()
+	if len(failed) != 2 || !strings.Contains(failed[0].Err.Error(), "ACCESS_DENIED") || !strings.Contains(failed[1].Err.Error(), "Invalid environment production") {
+		t.Errorf("Expected other and prod accounts failed, but got: %+v", failed)
+	}
+}
+
 // populateDonDominio(db)
 func TestPopulateDonDominio(t *testing.T) {
+	withTestConfig(t, "donDominio.list", "#id:user:pass\nbadProxy:user:pass\ntestId:user:pass\n")
+	// Accounts with invalid proxy fail alone
+	proxiesOri := proxies
+	proxies = proxyConfig{"dondominio/badProxy": "ftp://127.0.0.1:21"}
+	syncResults = nil
+
 	// Copy original functions content
 	getDonDominioDomainsOri := getDonDominioDomains
 	// unmock functions content
 	defer func() {
 		getDonDominioDomains = getDonDominioDomainsOri
+		proxies = proxiesOri
 	}()
 
 	getDonDominioDomains = func(client *http.Client, r *http.Request) (*http.Response, error) {
@@ -385,9 +526,17 @@ func TestPopulateDonDominio(t *testing.T) {
 		t.Fatalf("Failed to create table: %v", err)
 	}
 
-	if err := populateDonDominio(db, "nil"); err != nil {
+	if err := populateDonDominio(db); err != nil {
 		t.Errorf("Expected no error when checking populateDonDominio, but got: %v", err)
 	}
+
+	records, err := lookupDomain(db, "example.com")
+	if err != nil || len(records) != 1 || records[0].Expires != "2025-01-01" {
+		t.Errorf("Expected example.com with expiry date, but got: %+v %v", records, err)
+	}
+	if failed := failedAccounts(); len(failed) != 1 || failed[0].Account != "badProxy" {
+		t.Errorf("Expected badProxy account failed, but got: %+v", failed)
+	}
 }
 
 // Test populateDB
@@ -405,6 +554,13 @@ func TestPopulateDB(t *testing.T) {
 		populateDonDominio = populateDonDominioOri
 	}()
 
+	// Unmocked providers read an empty configuration directory instead of the user's one
+	configDirOri := configDir
+	defer func() {
+		configDir = configDirOri
+	}()
+	configDir = t.TempDir()
+
 	populateOvh = func(db *sql.DB) error {
 		return nil
 	}
@@ -414,7 +570,7 @@ func TestPopulateDB(t *testing.T) {
 	populateGoDaddy = func(db *sql.DB) error {
 		return nil
 	}
-	populateDonDominio = func(db *sql.DB, socks5 string) error {
+	populateDonDominio = func(db *sql.DB) error {
 		return nil
 	}
 
@@ -424,7 +580,7 @@ func TestPopulateDB(t *testing.T) {
 	}
 	defer db.Close()
 
-	if err := populateDB(db, "nil"); err != nil {
+	if err := populateDB(db, t.TempDir()+"/domain_list.db"); err != nil {
 		t.Errorf("Expected no error when populating db, but got: %v", err)
 	}
 
@@ -437,13 +593,24 @@ func TestPopulateDB(t *testing.T) {
 	populateGoDaddy = func(db *sql.DB) error {
 		return errors.New("populateGoDaddy error")
 	}
-	populateDonDominio = func(db *sql.DB, socks5 string) error {
+	populateDonDominio = func(db *sql.DB) error {
 		return errors.New("populateDonDominio error")
 	}
 
-	if err := populateDB(db, "nil"); err == nil {
+	// Providers errors are logged with their reason
+	loggerOri := logger
+	defer func() {
+		logger = loggerOri
+	}()
+	var logOutput bytes.Buffer
+	logger = newLogger(&logOutput, logJSON, slog.LevelInfo)
+
+	if err := populateDB(db, t.TempDir()+"/domain_list.db"); err == nil {
 		t.Errorf("Expected error when populating db, but got: %v", err)
 	}
+	if !strings.Contains(logOutput.String(), `"provider":"godaddy","operation":"populate","error":"populateGoDaddy error"`) {
+		t.Errorf("Expected provider error in log, but got: %s", logOutput.String())
+	}
 }
 
 // Test getDnsNs
@@ -533,21 +700,21 @@ func TestQueryDB(t *testing.T) {
 	}
 
 	// Search domain
-	err = queryDB("example.com", db, 0)
-	if err != nil {
-		t.Errorf("Expected no error when querying existing domain, but got: %v", err)
+	found, err := queryDB("example.com", db, 0)
+	if err != nil || !found {
+		t.Errorf("Expected domain found without error when querying existing domain, but got: %v %v", found, err)
 	}
 
 	// Search non-db domain
-	err = queryDB("alfaexploit.com", db, 0)
-	if err != nil {
-		t.Errorf("Expected no error when querying non-db domain, but got: %v", err)
+	found, err = queryDB("alfaexploit.com", db, 0)
+	if err != nil || found {
+		t.Errorf("Expected domain not found without error when querying non-db domain, but got: %v %v", found, err)
 	}
 
 	// Search inexistent domain
-	err = queryDB("nonexistent.com", db, 0)
-	if err != nil {
-		t.Errorf("Expected no error when querying nonexistent domain, but got: %v", err)
+	found, err = queryDB("nonexistent.com", db, 0)
+	if err != nil || found {
+		t.Errorf("Expected domain not found without error when querying nonexistent domain, but got: %v %v", found, err)
 	}
 }
 
@@ -596,6 +763,13 @@ func TestRegenerateDb(t *testing.T) {
 		checkPopulatedDb = checkPopulatedDbOri
 	}()
 
+	// Unmocked providers read an empty configuration directory instead of the user's one
+	configDirOri := configDir
+	defer func() {
+		configDir = configDirOri
+	}()
+	configDir = t.TempDir()
+
 	populateOvh = func(db *sql.DB) error {
 		return nil
 	}
@@ -605,7 +779,7 @@ func TestRegenerateDb(t *testing.T) {
 	populateGoDaddy = func(db *sql.DB) error {
 		return nil
 	}
-	populateDonDominio = func(db *sql.DB, socks5 string) error {
+	populateDonDominio = func(db *sql.DB) error {
 		return nil
 	}
 	checkPopulatedDb = func(db *sql.DB) error {
@@ -613,14 +787,14 @@ func TestRegenerateDb(t *testing.T) {
 	}
 
 	dbFile := "/tmp/testDb.db"
-	if err := regenerateDb(dbFile, "nil"); err != nil {
+	if err := regenerateDb(dbFile); err != nil {
 		t.Errorf("Expected no error when checking TestRegenerateDb, but got: %v", err)
 	}
 
 	checkPopulatedDb = func(db *sql.DB) error {
 		return fmt.Errorf("Error populating DB")
 	}
-	if err := regenerateDb(dbFile, "nil"); err == nil {
+	if err := regenerateDb(dbFile); err == nil {
 		t.Errorf("Expected error when checking TestRegenerateDb, but got: %v", err)
 	}
 
@@ -641,6 +815,13 @@ func TestMain(t *testing.T) {
 		populateDonDominio = populateDonDominioOri
 	}()
 
+	// Unmocked providers read an empty configuration directory instead of the user's one
+	configDirOri := configDir
+	defer func() {
+		configDir = configDirOri
+	}()
+	configDir = t.TempDir()
+
 	populateOvh = func(db *sql.DB) error {
 		return nil
 	}
@@ -650,24 +831,30 @@ func TestMain(t *testing.T) {
 	populateGoDaddy = func(db *sql.DB) error {
 		return nil
 	}
-	populateDonDominio = func(db *sql.DB, socks5 string) error {
+	populateDonDominio = func(db *sql.DB) error {
 		return nil
 	}
 	checkPopulatedDb = func(db *sql.DB) error {
 		return nil
 	}
 
-	// Save original Args and restore on exit function
-	oldArgs := os.Args
+	// Use test DB file
+	dbFileOri := dbFile
 	defer func() {
-		os.Args = oldArgs
+		dbFile = dbFileOri
 	}()
+	dbFile = "/tmp/testDb.db"
 
-	// Args reset
-	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-
-	// Configure new Args
-	os.Args = []string{"cmd", "-exit"}
+	// Banner is only shown in terminals
+	isTerminalOri := isTerminal
+	noColorOri := color.NoColor
+	defer func() {
+		isTerminal = isTerminalOri
+		color.NoColor = noColorOri
+	}()
+	isTerminal = func(f *os.File) bool {
+		return true
+	}
 
 	// Copy original functions content
 	// We cant unmock it using defer because maybe we need to make some prints in console for debugging
@@ -685,7 +872,7 @@ func TestMain(t *testing.T) {
 	color.Output = w
 	color.Error = w
 
-	main()
+	exitCode := run([]string{"-exit"})
 
 	// Close w pipe
 	w.Close()
@@ -696,6 +883,10 @@ func TestMain(t *testing.T) {
 	color.Output = colorOutputOri
 	color.Error = colorErrorOri
 
+	if exitCode != exitOK {
+		t.Errorf("Expected exit code %d, but got: %d", exitOK, exitCode)
+	}
+
 	// Read all r pipe content
 	out, _ := io.ReadAll(r)
 	//fmt.Println("--- out ---")
@@ -719,17 +910,17 @@ func TestMain(t *testing.T) {
 
 // Test main -regenerateDB
 func TestMainDbFileRegenerateDB(t *testing.T) {
-	dbFile := "/tmp/testDb.db"
+	testDbFile := "/tmp/testDb.db"
 
 	// Remove DB:
-	err := os.Remove(dbFile)
-	fileNotFoundError := "remove " + dbFile + ": no such file or directory"
+	err := os.Remove(testDbFile)
+	fileNotFoundError := "remove " + testDbFile + ": no such file or directory"
 	if err != nil && err.Error() != fileNotFoundError {
-		t.Fatalf(`Error deleting DB file: %s`, dbFile)
+		t.Fatalf(`Error deleting DB file: %s`, testDbFile)
 	}
 
 	// Create DB:
-	file, err := os.Create(dbFile)
+	file, err := os.Create(testDbFile)
 	if err != nil {
 		t.Fatalf(`Error TestMainDbFileRegenerateDB: %v`, err)
 	}
@@ -748,6 +939,13 @@ func TestMainDbFileRegenerateDB(t *testing.T) {
 		populateDonDominio = populateDonDominioOri
 	}()
 
+	// Unmocked providers read an empty configuration directory instead of the user's one
+	configDirOri := configDir
+	defer func() {
+		configDir = configDirOri
+	}()
+	configDir = t.TempDir()
+
 	populateOvh = func(db *sql.DB) error {
 		return nil
 	}
@@ -757,24 +955,19 @@ func TestMainDbFileRegenerateDB(t *testing.T) {
 	populateGoDaddy = func(db *sql.DB) error {
 		return nil
 	}
-	populateDonDominio = func(db *sql.DB, socks5 string) error {
+	populateDonDominio = func(db *sql.DB) error {
 		return nil
 	}
 	checkPopulatedDb = func(db *sql.DB) error {
 		return nil
 	}
 
-	// Save original Args and restore on exit function
-	oldArgs := os.Args
+	// Use test DB file
+	dbFileOri := dbFile
 	defer func() {
-		os.Args = oldArgs
+		dbFile = dbFileOri
 	}()
-
-	// Args reset
-	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-
-	// Configure new Args
-	os.Args = []string{"cmd", "-regenerateDB", "-exit"}
+	dbFile = testDbFile
 
 	// Copy original functions content
 	// We cant unmock it using defer because maybe we need to make some prints in console for debugging
@@ -793,7 +986,7 @@ func TestMainDbFileRegenerateDB(t *testing.T) {
 	color.Output = w
 	color.Error = w
 
-	main()
+	run([]string{"-regenerateDB", "-exit"})
 
 	// Close w pipe
 	w.Close()
@@ -0,0 +1,202 @@
+package main
+
+// Domain inventory changes between syncs: domains added to, removed from or moved between accounts
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Domain events
+const (
+	eventAdded   = "added"
+	eventRemoved = "removed"
+	eventMoved   = "moved"
+	// Notifications only, they aren't recorded in domain_events
+	eventExpiring = "expiring"
+)
+
+type domainEvent struct {
+	Time   string `json:"time"`
+	Domain string `json:"domain"`
+	Event  string `json:"event"`
+	// Holder account, removed one for removed events
+	ISP     string `json:"isp"`
+	Account string `json:"account"`
+	// Moved events only
+	PreviousISP     string `json:"previousIsp,omitempty"`
+	PreviousAccount string `json:"previousAccount,omitempty"`
+	// Expiring events only
+	Expires string `json:"expires,omitempty"`
+}
+
+func (e domainEvent) String() string {
+	switch e.Event {
+	case eventMoved:
+		return fmt.Sprintf("%s  %-7s  %s: %s / %s -> %s / %s", e.Time, e.Event, e.Domain, e.PreviousISP, e.PreviousAccount, e.ISP, e.Account)
+	case eventExpiring:
+		return fmt.Sprintf("%s  %-7s  %s: %s / %s expires %s", e.Time, e.Event, e.Domain, e.ISP, e.Account, e.Expires)
+	default:
+		return fmt.Sprintf("%s  %-7s  %s: %s / %s", e.Time, e.Event, e.Domain, e.ISP, e.Account)
+	}
+}
+
+const createEventsTableSQL = `CREATE TABLE IF NOT EXISTS domain_events ( "id" INTEGER PRIMARY KEY AUTOINCREMENT, "time" VARCHAR(30), "domain" VARCHAR(100), "event" VARCHAR(10), "isp" VARCHAR(100), "account" VARCHAR(100), "previous_isp" VARCHAR(100) DEFAULT '', "previous_account" VARCHAR(100) DEFAULT '');`
+
+const domainEventColumns = "time, domain, event, isp, account, previous_isp, previous_account"
+
+type domainHolder struct {
+	ISP     string
+	Account string
+}
+
+// Get domains holders from schema domain_list, transferred out domains have none
+func readHolders(tx *sql.Tx, schema string) (map[string][]domainHolder, error) {
+	rows, err := tx.Query("SELECT DISTINCT domain, isp, id FROM " + schema + ".domain_list WHERE " + heldDomainsWhere + " ORDER BY domain, isp, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := map[string][]domainHolder{}
+	for rows.Next() {
+		var domain string
+		var holder domainHolder
+		if err := rows.Scan(&domain, &holder.ISP, &holder.Account); err != nil {
+			return nil, err
+		}
+		holders[domain] = append(holders[domain], holder)
+	}
+	return holders, rows.Err()
+}
+
+// Holders in a but not in b
+func missingHolders(a, b []domainHolder) []domainHolder {
+	missing := []domainHolder{}
+	for _, holder := range a {
+		found := false
+		for _, other := range b {
+			if holder == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, holder)
+		}
+	}
+	return missing
+}
+
+// Get events from previous to current domains holders, a holder replaced by another one is a move
+func diffHolders(at string, previous, current map[string][]domainHolder) []domainEvent {
+	domains := []string{}
+	for domain := range previous {
+		domains = append(domains, domain)
+	}
+	for domain := range current {
+		if _, ok := previous[domain]; !ok {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+
+	events := []domainEvent{}
+	for _, domain := range domains {
+		removed := missingHolders(previous[domain], current[domain])
+		added := missingHolders(current[domain], previous[domain])
+		for i := 0; i < len(removed) || i < len(added); i++ {
+			switch {
+			case i < len(removed) && i < len(added):
+				events = append(events, domainEvent{Time: at, Domain: domain, Event: eventMoved, ISP: added[i].ISP, Account: added[i].Account, PreviousISP: removed[i].ISP, PreviousAccount: removed[i].Account})
+			case i < len(added):
+				events = append(events, domainEvent{Time: at, Domain: domain, Event: eventAdded, ISP: added[i].ISP, Account: added[i].Account})
+			default:
+				events = append(events, domainEvent{Time: at, Domain: domain, Event: eventRemoved, ISP: removed[i].ISP, Account: removed[i].Account})
+			}
+		}
+	}
+	return events
+}
+
+// Record changes from main to regenerated attached DB domain_list, called before main one is replaced
+func recordDomainEvents(tx *sql.Tx, at time.Time) ([]domainEvent, error) {
+	if _, err := tx.Exec(createEventsTableSQL); err != nil {
+		return nil, err
+	}
+	previous, err := readHolders(tx, "main")
+	if err != nil {
+		return nil, err
+	}
+	// First sync, or DB lost: every domain would be notified as added
+	if len(previous) == 0 {
+		return nil, nil
+	}
+	current, err := readHolders(tx, "regenerated")
+	if err != nil {
+		return nil, err
+	}
+
+	events := diffHolders(at.UTC().Format(time.RFC3339), previous, current)
+	for _, event := range events {
+		_, err := tx.Exec("INSERT INTO main.domain_events ("+domainEventColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+			event.Time, event.Domain, event.Event, event.ISP, event.Account, event.PreviousISP, event.PreviousAccount)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// Get events matching condition, oldest first
+func queryDomainEvents(db *sql.DB, where string, args ...interface{}) ([]domainEvent, error) {
+	if _, err := db.Exec(createEventsTableSQL); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT "+domainEventColumns+" FROM domain_events WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []domainEvent{}
+	for rows.Next() {
+		var event domainEvent
+		if err := rows.Scan(&event.Time, &event.Domain, &event.Event, &event.ISP, &event.Account, &event.PreviousISP, &event.PreviousAccount); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func domainHistory(db *sql.DB, domain string) ([]domainEvent, error) {
+	return queryDomainEvents(db, "domain = ?", domain)
+}
+
+func domainEventsSince(db *sql.DB, since time.Time) ([]domainEvent, error) {
+	return queryDomainEvents(db, "time >= ?", since.UTC().Format(time.RFC3339))
+}
+
+// Parse -since value: duration, days as 7d, date or RFC3339 time
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid since value %s, use a duration as 24h or 7d, a date as 2006-01-02 or RFC3339 time", value)
+}
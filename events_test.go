@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test events between holders sets
+func TestDiffHolders(t *testing.T) {
+	previous := map[string][]domainHolder{
+		"kept.com":    {{"ovh", "1"}},
+		"moved.com":   {{"ovh", "1"}},
+		"removed.com": {{"ovh", "1"}},
+		"shared.com":  {{"ovh", "1"}, {"cloudflare", "2"}},
+	}
+	current := map[string][]domainHolder{
+		"added.com":  {{"cloudflare", "2"}},
+		"kept.com":   {{"ovh", "1"}},
+		"moved.com":  {{"cloudflare", "2"}},
+		"shared.com": {{"cloudflare", "2"}},
+	}
+
+	events := diffHolders("2024-01-01T00:00:00Z", previous, current)
+	expected := []string{
+		"2024-01-01T00:00:00Z  added    added.com: cloudflare / 2",
+		"2024-01-01T00:00:00Z  moved    moved.com: ovh / 1 -> cloudflare / 2",
+		"2024-01-01T00:00:00Z  removed  removed.com: ovh / 1",
+		"2024-01-01T00:00:00Z  removed  shared.com: ovh / 1",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, but got: %+v", len(expected), events)
+	}
+	for i, event := range events {
+		if event.String() != expected[i] {
+			t.Errorf("Expected %s, but got: %s", expected[i], event)
+		}
+	}
+}
+
+// Test syncs record domain events shown by history and diff commands
+func TestDomainEvents(t *testing.T) {
+	db := withTestDb(t)
+	mockProvidersSync(t, false)
+	regenerateDb(dbFile)
+
+	events, err := domainEventsSince(db, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	counts := map[string]int{}
+	for _, event := range events {
+		counts[event.Event]++
+	}
+	if counts[eventAdded] != 3 || counts[eventRemoved] != 3 || counts[eventMoved] != 0 {
+		t.Errorf("Expected 3 added and 3 removed events, but got: %+v", events)
+	}
+
+	// Failed accounts keep their domains, they are not removed
+	mockProvidersSync(t, true)
+	regenerateDb(dbFile)
+	if history, _ := domainHistory(db, "second.com"); len(history) != 1 || history[0].Event != eventAdded {
+		t.Errorf("Expected second.com only added, but got: %+v", history)
+	}
+
+	exitCode, out := captureRun(t, "history", "example.com")
+	if exitCode != exitOK || !strings.Contains(out, "removed  example.com: ovh / 1") || !strings.Contains(out, "removed  example.com: cloudflare / 2") {
+		t.Errorf("Expected example.com removed events, but got: %d %s", exitCode, out)
+	}
+	if exitCode, out := captureRun(t, "history", "bad_domain.com"); exitCode != exitUsage {
+		t.Errorf("Expected usage error, but got: %d %s", exitCode, out)
+	}
+
+	exitCode, out = captureRun(t, "diff", "-since", "7d")
+	if exitCode != exitOK || !strings.Contains(out, "added    new.com: ovh / ovhId1") || !strings.Contains(out, "3 added, 3 removed, 0 moved") {
+		t.Errorf("Expected diff events, but got: %d %s", exitCode, out)
+	}
+	if exitCode, out := captureRun(t, "diff", "-since", "2999-01-01"); exitCode != exitOK || !strings.Contains(out, "0 added, 0 removed, 0 moved") {
+		t.Errorf("Expected no future events, but got: %d %s", exitCode, out)
+	}
+	if exitCode, out := captureRun(t, "diff", "-since", "last week"); exitCode != exitUsage {
+		t.Errorf("Expected usage error, but got: %d %s", exitCode, out)
+	}
+}
+
+// Test first sync doesn't record every domain as added
+func TestDomainEventsFirstSync(t *testing.T) {
+	db := withTestDb(t)
+	if _, err := db.Exec("DELETE FROM domain_list"); err != nil {
+		t.Fatal(err)
+	}
+	mockProvidersSync(t, false)
+	regenerateDb(dbFile)
+
+	if events, err := domainEventsSince(db, time.Now().Add(-time.Hour)); err != nil || len(events) != 0 {
+		t.Errorf("Expected no events, but got: %+v %v", events, err)
+	}
+	if records, _ := lookupDomain(db, "new.com"); len(records) != 1 {
+		t.Errorf("Expected new.com synced, but got: %+v", records)
+	}
+}
+
+// Test -since values
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for value, expected := range map[string]time.Time{
+		"24h":                  now.Add(-24 * time.Hour),
+		"7d":                   now.AddDate(0, 0, -7),
+		"2024-03-01T00:00:00Z": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if since, err := parseSince(value, now); err != nil || !since.Equal(expected) {
+			t.Errorf("%s: expected %s, but got: %s %v", value, expected, since, err)
+		}
+	}
+	if since, err := parseSince("2024-03-01", now); err != nil || since.Day() != 1 || since.Location() != time.Local {
+		t.Errorf("Expected local date, but got: %s %v", since, err)
+	}
+	for _, value := range []string{"", "-1h", "7days", "yesterday"} {
+		if _, err := parseSince(value, now); err == nil {
+			t.Errorf("%s: expected error", value)
+		}
+	}
+}
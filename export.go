@@ -0,0 +1,164 @@
+package main
+
+// Domain inventory export as CSV, JSON or XLSX workbook with one sheet per ISP
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Export formats
+const (
+	exportFormatCSV  = "csv"
+	exportFormatJSON = "json"
+	exportFormatXLSX = "xlsx"
+)
+
+var exportColumns = []string{"id", "realId", "isp", "domain", "endpoint", "expires", "source", "status"}
+
+func exportRow(record domainRecord) []string {
+	return []string{record.ID, record.RealID, record.ISP, record.Domain, record.Endpoint, record.Expires, record.Source, record.Status}
+}
+
+// Get export format from -format flag or output file extension, CSV by default
+func exportFormat(format, output string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(output)), ".")
+		if format != exportFormatJSON && format != exportFormatXLSX {
+			format = exportFormatCSV
+		}
+	}
+	switch format {
+	case exportFormatCSV, exportFormatJSON, exportFormatXLSX:
+		return format, nil
+	}
+	return "", fmt.Errorf("Invalid export format %s, use %s, %s or %s", format, exportFormatCSV, exportFormatJSON, exportFormatXLSX)
+}
+
+// Get domains held by given ISPs and accounts, id or realId, with given TLDs, empty lists match all of them
+func exportRecords(db *sql.DB, isps, accounts, tlds []string) ([]domainRecord, error) {
+	// Same restrictions as API tokens ones
+	where, args := (&apiToken{ISPs: isps, Accounts: accounts}).where()
+	if len(tlds) > 0 {
+		conditions := []string{}
+		for _, tld := range tlds {
+			conditions = append(conditions, "domain LIKE ?")
+			args = append(args, "%."+strings.ToLower(strings.TrimPrefix(tld, ".")))
+		}
+		where += " AND (" + strings.Join(conditions, " OR ") + ")"
+	}
+
+	rows, err := db.Query("SELECT "+domainRecordColumns+" FROM domain_list WHERE "+where+" ORDER BY isp, id, domain", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDomainRecords(rows)
+}
+
+func exportCSV(records []domainRecord, output io.Writer) error {
+	writer := csv.NewWriter(output)
+	writer.Write(exportColumns)
+	for _, record := range records {
+		writer.Write(exportRow(record))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func exportJSON(records []domainRecord, output io.Writer) error {
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// Characters not allowed in sheet names
+var xlsxSheetNameReplacer = strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "_", "]", "_")
+
+// Get ISP sheet name, names are limited to 31 characters and unique ignoring case: clashing ones get a numeric suffix.
+// Forbidden characters, and quotes starting or ending names, are replaced by _
+func xlsxSheetName(isp string, used map[string]bool) string {
+	name := []rune(xlsxSheetNameReplacer.Replace(isp))
+	if len(name) > 31 {
+		name = name[:31]
+	}
+	if len(name) == 0 {
+		name = []rune("_")
+	}
+	if name[0] == '\'' {
+		name[0] = '_'
+	}
+	if name[len(name)-1] == '\'' {
+		name[len(name)-1] = '_'
+	}
+	sheet := string(name)
+	for i := 2; used[strings.ToLower(sheet)]; i++ {
+		suffix := fmt.Sprintf("~%d", i)
+		if len(name) > 31-len(suffix) {
+			name = name[:31-len(suffix)]
+		}
+		sheet = string(name) + suffix
+	}
+	used[strings.ToLower(sheet)] = true
+	return sheet
+}
+
+// One sheet per ISP, records are sorted by ISP
+func exportXLSX(records []domainRecord, output io.Writer) error {
+	workbook := excelize.NewFile()
+	defer workbook.Close()
+	defaultSheet := workbook.GetSheetName(0)
+
+	sheets := map[string]string{}
+	usedSheets := map[string]bool{}
+	sheetRows := map[string]int{}
+	for _, record := range records {
+		sheet, ok := sheets[record.ISP]
+		if !ok {
+			sheet = xlsxSheetName(record.ISP, usedSheets)
+			sheets[record.ISP] = sheet
+			if _, err := workbook.NewSheet(sheet); err != nil {
+				return err
+			}
+			if err := workbook.SetSheetRow(sheet, "A1", &exportColumns); err != nil {
+				return err
+			}
+			sheetRows[sheet] = 1
+		}
+		sheetRows[sheet]++
+		row := exportRow(record)
+		if err := workbook.SetSheetRow(sheet, fmt.Sprintf("A%d", sheetRows[sheet]), &row); err != nil {
+			return err
+		}
+	}
+
+	// Default sheet is only kept in empty exports
+	if len(sheetRows) == 0 {
+		if err := workbook.SetSheetRow(defaultSheet, "A1", &exportColumns); err != nil {
+			return err
+		}
+	} else if !usedSheets[strings.ToLower(defaultSheet)] {
+		if err := workbook.DeleteSheet(defaultSheet); err != nil {
+			return err
+		}
+	}
+	return workbook.Write(output)
+}
+
+func exportDomains(records []domainRecord, format string, output io.Writer) error {
+	switch format {
+	case exportFormatJSON:
+		return exportJSON(records, output)
+	case exportFormatXLSX:
+		return exportXLSX(records, output)
+	default:
+		return exportCSV(records, output)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Test export filters
+func TestExportRecords(t *testing.T) {
+	db := withTestDb(t)
+	if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("1", "realId", "ovh", "example.es")`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		isps, accounts, tlds []string
+		expected             string
+	}{
+		{nil, nil, nil, "cloudflare/example.com cloudflare/example.net ovh/example.com ovh/example.es"},
+		{[]string{"ovh"}, nil, nil, "ovh/example.com ovh/example.es"},
+		{nil, []string{"otherRealId"}, nil, "cloudflare/example.com cloudflare/example.net"},
+		{nil, nil, []string{"net", ".es"}, "cloudflare/example.net ovh/example.es"},
+		{[]string{"ovh"}, []string{"2"}, nil, ""},
+	} {
+		records, err := exportRecords(db, test.isps, test.accounts, test.tlds)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		domains := []string{}
+		for _, record := range records {
+			domains = append(domains, record.ISP+"/"+record.Domain)
+		}
+		if strings.Join(domains, " ") != test.expected {
+			t.Errorf("%v %v %v: expected %s, but got: %v", test.isps, test.accounts, test.tlds, test.expected, domains)
+		}
+	}
+}
+
+// Test export format selection
+func TestExportFormat(t *testing.T) {
+	for _, test := range []struct {
+		format, output, expected string
+	}{
+		{"", "-", exportFormatCSV},
+		{"", "domains.XLSX", exportFormatXLSX},
+		{"", "domains.json", exportFormatJSON},
+		{"", "domains.txt", exportFormatCSV},
+		{"json", "domains.csv", exportFormatJSON},
+	} {
+		if format, err := exportFormat(test.format, test.output); err != nil || format != test.expected {
+			t.Errorf("%s %s: expected %s, but got: %s %v", test.format, test.output, test.expected, format, err)
+		}
+	}
+	if _, err := exportFormat("ods", "-"); err == nil {
+		t.Errorf("Expected invalid format error")
+	}
+}
+
+// Test XLSX export has one sheet per ISP
+func TestExportXLSX(t *testing.T) {
+	db := withTestDb(t)
+	records, _ := exportRecords(db, nil, nil, nil)
+
+	var output bytes.Buffer
+	if err := exportXLSX(records, &output); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	workbook, err := excelize.OpenReader(&output)
+	if err != nil {
+		t.Fatalf("Expected valid workbook, but got: %v", err)
+	}
+	defer workbook.Close()
+
+	if sheets := workbook.GetSheetList(); strings.Join(sheets, ",") != "cloudflare,ovh" {
+		t.Errorf("Expected cloudflare and ovh sheets, but got: %v", sheets)
+	}
+	rows, _ := workbook.GetRows("cloudflare")
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(exportColumns, ",") || rows[2][3] != "example.net" {
+		t.Errorf("Unexpected cloudflare sheet rows: %v", rows)
+	}
+
+	// Long ISP names truncated to the same sheet name
+	long := strings.Repeat("x", 31)
+	records = []domainRecord{{ISP: long + "-a", Domain: "a.com"}, {ISP: long + "-b", Domain: "b.com"}, {ISP: "OVH", Domain: "c.com"}, {ISP: "ovh", Domain: "d.com"}}
+	output.Reset()
+	if err := exportXLSX(records, &output); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	workbook, err = excelize.OpenReader(&output)
+	if err != nil {
+		t.Fatalf("Expected valid workbook, but got: %v", err)
+	}
+	defer workbook.Close()
+	expected := []string{long, strings.Repeat("x", 29) + "~2", "OVH", "ovh~2"}
+	if sheets := workbook.GetSheetList(); strings.Join(sheets, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v sheets, but got: %v", expected, sheets)
+	}
+	if rows, _ := workbook.GetRows(expected[1]); len(rows) != 2 || rows[1][3] != "b.com" {
+		t.Errorf("Unexpected %s sheet rows: %v", expected[1], rows)
+	}
+
+	// ISP names with characters not allowed in sheet names
+	records = []domainRecord{{ISP: "acme/legacy", Domain: "a.com"}, {ISP: "'a:b\\c?d*e[f]'", Domain: "b.com"}, {ISP: "acme_legacy", Domain: "c.com"}}
+	output.Reset()
+	if err := exportXLSX(records, &output); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	workbook, err = excelize.OpenReader(&output)
+	if err != nil {
+		t.Fatalf("Expected valid workbook, but got: %v", err)
+	}
+	defer workbook.Close()
+	expected = []string{"acme_legacy", "_a_b_c_d_e_f__", "acme_legacy~2"}
+	if sheets := workbook.GetSheetList(); strings.Join(sheets, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v sheets, but got: %v", expected, sheets)
+	}
+
+	// Empty exports keep default sheet with columns header
+	output.Reset()
+	if err := exportXLSX(nil, &output); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	workbook, err = excelize.OpenReader(&output)
+	if err != nil {
+		t.Fatalf("Expected valid workbook, but got: %v", err)
+	}
+	defer workbook.Close()
+	if sheets := workbook.GetSheetList(); len(sheets) != 1 {
+		t.Errorf("Expected default sheet, but got: %v", sheets)
+	}
+}
+
+// Test export command
+func TestExportCommand(t *testing.T) {
+	withTestDb(t)
+	outputFile := t.TempDir() + "/domains.json"
+
+	if exitCode, out := captureRun(t, "export", "-o", outputFile, "-isp", "cloudflare", "-tld", "com"); exitCode != exitOK {
+		t.Fatalf("Expected export, but got: %d %s", exitCode, out)
+	}
+	content, _ := os.ReadFile(outputFile)
+	var records []domainRecord
+	if err := json.Unmarshal(content, &records); err != nil || len(records) != 1 || records[0].Domain != "example.com" || records[0].ISP != "cloudflare" {
+		t.Errorf("Expected cloudflare example.com JSON export, but got: %v %s", err, content)
+	}
+
+	if exitCode, _ := captureRun(t, "export", "-format", "pdf"); exitCode != exitUsage {
+		t.Errorf("Expected usage error, but got: %d", exitCode)
+	}
+}
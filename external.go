@@ -0,0 +1,173 @@
+package main
+
+// External command providers for inventories not worth a native provider: commands receive account configuration
+// on stdin and print domains as JSON lines
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Commands file in configuration directory, one per line: isp:account:timeout:command [args...]
+// timeout defaults to 60s, commands aren't run through a shell and relative paths are relative to configuration directory
+var externalFile = "external.list"
+
+var externalDefaultTimeout = 60 * time.Second
+
+// Command stdin, config is isp.conf file content when it exists in configuration directory
+type externalInput struct {
+	ISP     string `json:"isp"`
+	Account string `json:"account"`
+	Config  string `json:"config"`
+}
+
+// Command output line, realId defaults to account
+type externalDomain struct {
+	Domain   string `json:"domain"`
+	RealID   string `json:"realId"`
+	Endpoint string `json:"endpoint"`
+	Expires  string `json:"expires"`
+}
+
+// Run command with timeout, returns its stdout
+var runExternalCommand = func(timeout time.Duration, command []string, stdin []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = configDir
+	// Children inheriting stdout could keep it open after timeout
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("Command timed out after %s", timeout)
+	}
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > 200 {
+			message = message[:200]
+		}
+		return nil, fmt.Errorf("%v: %s", err, message)
+	}
+	return stdout.Bytes(), nil
+}
+
+// Parse command JSON lines output, invalid domains are returned apart so they are logged and skipped
+func parseExternalDomains(output []byte) ([]externalDomain, []string, error) {
+	domains := []externalDomain{}
+	invalid := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var domain externalDomain
+		if err := json.Unmarshal([]byte(line), &domain); err != nil {
+			return nil, nil, fmt.Errorf("Output line %d: %v", lineNumber, err)
+		}
+		domain.Domain = strings.ToLower(strings.TrimSuffix(domain.Domain, "."))
+		if len(domain.Domain) >= 100 || checkDNS(domain.Domain) != nil {
+			invalid = append(invalid, domain.Domain)
+			continue
+		}
+		if domain.Expires != "" {
+			if _, err := time.Parse(expiresFormat, domain.Expires); err != nil {
+				return nil, nil, fmt.Errorf("Output line %d: invalid expires %q, format: %s", lineNumber, domain.Expires, expiresFormat)
+			}
+		}
+		domains = append(domains, domain)
+	}
+	return domains, invalid, scanner.Err()
+}
+
+var populateExternal = func(db *sql.DB) error {
+	log := logger.With("provider", "external")
+	accounts, err := readAccountsFile(configPath(externalFile), 4, 4)
+	if err != nil {
+		log.Error("Unable to read commands file", "operation", "read_config", "syntax", "isp:account:timeout:command [args...]", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting external commands data:")
+	for _, dataFields := range accounts {
+		isp, account, command := dataFields[0], dataFields[1], strings.Fields(dataFields[3])
+		render.Info("-- %s account: %s", isp, account)
+		// Accounts are recorded by ISP, so failed ones keep their previous domains
+		log := providerLogger(isp, account)
+		if account == "" || len(command) == 0 {
+			err := fmt.Errorf("Missing account or command")
+			log.Error("Invalid command line", "operation", "read_config", "error", err)
+			recordAccountSync(isp, account, 0, err)
+			continue
+		}
+		timeout := externalDefaultTimeout
+		if dataFields[2] != "" {
+			if timeout, err = time.ParseDuration(dataFields[2]); err != nil {
+				log.Error("Invalid timeout, use durations as 30s or 5m", "operation", "read_config", "error", err)
+				recordAccountSync(isp, account, 0, err)
+				continue
+			}
+		}
+		if strings.Contains(command[0], "/") && !filepath.IsAbs(command[0]) {
+			command[0] = configPath(command[0])
+		}
+
+		input := externalInput{ISP: isp, Account: account}
+		if config, err := os.ReadFile(configPath(isp + ".conf")); err == nil {
+			input.Config = string(config)
+		}
+		stdin, _ := json.Marshal(input)
+
+		output, err := runExternalCommand(timeout, command, stdin)
+		if err != nil {
+			log.Error("Command failed", "operation", "list_domains", "command", command[0], "error", err)
+			recordAccountSync(isp, account, 0, err)
+			continue
+		}
+		domains, invalid, err := parseExternalDomains(output)
+		if err != nil {
+			log.Error("Invalid command output, expected JSON lines", "operation", "list_domains", "command", command[0], "error", err)
+			recordAccountSync(isp, account, 0, err)
+			continue
+		}
+		for _, domain := range invalid {
+			log.Warn("Skipping invalid domain", "operation", "list_domains", "domain", domain)
+		}
+
+		records := []domainRecord{}
+		for _, domain := range domains {
+			realId := domain.RealID
+			if realId == "" {
+				realId = account
+			}
+			records = append(records, domainRecord{ID: account, RealID: realId, ISP: isp, Domain: domain.Domain, Endpoint: domain.Endpoint, Expires: domain.Expires})
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert domains", "operation", "insert", "error", err)
+			recordAccountSync(isp, account, 0, err)
+			continue
+		}
+		recordAccountSync(isp, account, len(records), nil)
+	}
+	return nil
+}
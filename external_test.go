@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test external commands input, JSON lines output, invalid domains and timeouts
+func TestPopulateExternal(t *testing.T) {
+	withTestConfig(t, "inventory.conf", "token=secret\n")
+	script := "#!/bin/sh\n" +
+		"input=$(cat)\n" +
+		"case \"$input\" in *'\"account\":\"legacy\"'*'token=secret'*) ;; *) echo \"unexpected input: $input\" >&2; exit 1 ;; esac\n" +
+		"echo '{\"domain\":\"Legacy.com.\",\"expires\":\"2030-05-01\"}'\n" +
+		"echo '{\"domain\":\"bad_domain.com\"}'\n" +
+		"echo '{\"domain\":\"other.com\",\"realId\":\"client7\",\"endpoint\":\"eu\"}'\n"
+	if err := os.MkdirAll(configPath("bin"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"bin/inventory.sh": script, "bin/slow.sh": "#!/bin/sh\nexec sleep 5\n", "bin/broken.sh": "#!/bin/sh\necho 'example.com'\n"} {
+		if err := os.WriteFile(configPath(name), []byte(content), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(configPath(externalFile), []byte("#isp:account:timeout:command [args...]\ninventory:legacy::bin/inventory.sh\ninventory:slow:200ms:bin/slow.sh\ninventory:broken::sh bin/broken.sh\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	syncResults = nil
+	records, err := populateTestDb(t, populateExternal)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 domains, but got: %+v %v", records, err)
+	}
+	for i, expected := range []domainRecord{
+		{ID: "legacy", RealID: "legacy", ISP: "inventory", Domain: "legacy.com", Expires: "2030-05-01"},
+		{ID: "legacy", RealID: "client7", ISP: "inventory", Domain: "other.com", Endpoint: "eu"},
+	} {
+		if records[i] != expected {
+			t.Errorf("Expected %+v, but got: %+v", expected, records[i])
+		}
+	}
+
+	failed := failedAccounts()
+	if len(failed) != 2 || failed[0].Account != "slow" || !strings.Contains(failed[0].Err.Error(), "timed out after 200ms") {
+		t.Fatalf("Expected slow and broken accounts failed, but got: %+v", failed)
+	}
+	if failed[1].Provider != "inventory" || !strings.Contains(failed[1].Err.Error(), "Output line 1") {
+		t.Errorf("Expected broken account output error, but got: %+v", failed[1])
+	}
+}
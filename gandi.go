@@ -0,0 +1,111 @@
+package main
+
+// Gandi registered domains, v5 REST API with personal access tokens
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Credentials file in configuration directory, one per line: gandiId:personalAccessToken[:sharingId]
+// sharingId lists given organization domains
+var gandiFile = "gandi.list"
+
+var gandiEndpoint = "https://api.gandi.net"
+
+const gandiPageSize = 100
+
+type gandiDomain struct {
+	FQDN  string `json:"fqdn"`
+	Dates struct {
+		RegistryEndsAt time.Time `json:"registry_ends_at"`
+	} `json:"dates"`
+}
+
+// Get all domains requesting pages until a partial one is returned
+var getGandiDomains = func(client *http.Client, token, sharingId string) ([]gandiDomain, error) {
+	domains := []gandiDomain{}
+	for page := 1; ; page++ {
+		query := url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(gandiPageSize)}}
+		if sharingId != "" {
+			query.Set("sharing_id", sharingId)
+		}
+		r, err := http.NewRequest(http.MethodGet, gandiEndpoint+"/v5/domain/domains?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		var response []gandiDomain
+		if err := getProviderJSON(client, r, &response); err != nil {
+			return nil, err
+		}
+		domains = append(domains, response...)
+		if len(response) < gandiPageSize {
+			return domains, nil
+		}
+	}
+}
+
+var populateGandi = func(db *sql.DB) error {
+	log := logger.With("provider", "gandi")
+	accounts, err := readAccountsFile(configPath(gandiFile), 2, 3)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "syntax", "gandiId:personalAccessToken[:sharingId]", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting Gandi data:")
+	for _, dataFields := range accounts {
+		gandiId, token, sharingId := dataFields[0], dataFields[1], accountField(dataFields, 2)
+		// Organization is used as realId
+		realId := sharingId
+		if realId == "" {
+			realId = gandiId
+		}
+		render.Info("-- gandiId: %s", gandiId)
+		log := providerLogger("gandi", gandiId)
+
+		client, err := newProviderClient("gandi", gandiId)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("gandi", gandiId, 0, err)
+			continue
+		}
+		domains, err := getGandiDomains(client, token, sharingId)
+		if err != nil {
+			log.Error("Unable to list domains", "operation", "list_domains", "error", err)
+			recordAccountSync("gandi", gandiId, 0, err)
+			continue
+		}
+
+		records := []domainRecord{}
+		for _, domain := range domains {
+			name := strings.ToLower(strings.TrimSuffix(domain.FQDN, "."))
+			if err := checkDNS(name); err != nil {
+				log.Warn("Skipping invalid domain name", "operation", "list_domains", "domain", domain.FQDN, "error", err)
+				continue
+			}
+			record := domainRecord{ID: gandiId, RealID: realId, ISP: "gandi", Domain: name}
+			if !domain.Dates.RegistryEndsAt.IsZero() {
+				record.Expires = domain.Dates.RegistryEndsAt.UTC().Format(expiresFormat)
+			}
+			records = append(records, record)
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert domains", "operation", "insert", "error", err)
+			recordAccountSync("gandi", gandiId, 0, err)
+			continue
+		}
+		recordAccountSync("gandi", gandiId, len(records), nil)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test personal access token, organization and pagination against a local Gandi stand-in
+func TestPopulateGandi(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v5/domain/domains" || r.Header.Get("Authorization") != "Bearer pat" {
+			http.Error(w, `{"code":401,"message":"The server could not verify that you authorized to access the document you requested.","object":"HTTPUnauthorized","cause":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("sharing_id") != "org1" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		domains := []string{}
+		if r.URL.Query().Get("page") == "1" {
+			for i := 0; i < gandiPageSize; i++ {
+				domains = append(domains, fmt.Sprintf(`{"fqdn":"domain%d.fr","dates":{"registry_ends_at":"2030-01-15T10:00:00Z"}}`, i))
+			}
+		} else {
+			// Invalid names are skipped
+			domains = append(domains, `{"fqdn":"last.eu","dates":{}}`, `{"fqdn":"bad_name.eu","dates":{}}`)
+		}
+		fmt.Fprint(w, "["+strings.Join(domains, ",")+"]")
+	}))
+	defer server.Close()
+
+	gandiEndpointOri := gandiEndpoint
+	defer func() {
+		gandiEndpoint = gandiEndpointOri
+	}()
+	gandiEndpoint = server.URL
+
+	withTestConfig(t, gandiFile, "#gandiId:personalAccessToken[:sharingId]\nagency:pat:org1\nexpired:oldPat\n")
+	syncResults = nil
+	records, err := populateTestDb(t, populateGandi)
+	if err != nil || len(records) != gandiPageSize+1 {
+		t.Fatalf("Expected %d domains, but got: %d %v", gandiPageSize+1, len(records), err)
+	}
+	if records[0] != (domainRecord{ID: "agency", RealID: "org1", ISP: "gandi", Domain: "domain0.fr", Expires: "2030-01-15"}) {
+		t.Errorf("Unexpected first domain: %+v", records[0])
+	}
+	if failed := failedAccounts(); len(failed) != 1 || failed[0].Account != "expired" || !strings.Contains(failed[0].Err.Error(), "401") {
+		t.Errorf("Expected expired token account failed, but got: %+v", failed)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+// GCP Cloud DNS managed zones, service account credentials are exchanged for an access token using a signed JWT
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Credentials file in configuration directory, one per line: gcpId:project[,project...]:serviceAccountKeyFile
+// relative key files are relative to configuration directory
+var gcpFile = "gcp.list"
+
+var gcpDNSEndpoint = "https://dns.googleapis.com"
+
+const gcpDNSScope = "https://www.googleapis.com/auth/ndev.clouddns.readonly"
+
+// Service account JSON key fields
+type gcpServiceAccount struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+type gcpManagedZone struct {
+	Name       string `json:"name"`
+	DNSName    string `json:"dnsName"`
+	Visibility string `json:"visibility"`
+}
+
+func loadGCPServiceAccount(file string) (*gcpServiceAccount, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	account := &gcpServiceAccount{}
+	if err := json.Unmarshal(content, account); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("%s: missing client_email or private_key, expected a service account JSON key", file)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return account, nil
+}
+
+// JWT assertion signed with service account key
+func gcpAssertion(account *gcpServiceAccount, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("Invalid private_key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("Unsupported private_key type, expected RSA")
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": account.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   account.ClientEmail,
+		"scope": gcpDNSScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+var getGCPToken = func(client *http.Client, account *gcpServiceAccount) (string, error) {
+	assertion, err := gcpAssertion(account, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fetchOAuthToken(client, account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// Get project managed zones following page tokens
+var getGCPZones = func(client *http.Client, token, project string) ([]gcpManagedZone, error) {
+	zones := []gcpManagedZone{}
+	pageToken := ""
+	for {
+		apiUrl := gcpDNSEndpoint + "/dns/v1/projects/" + url.PathEscape(project) + "/managedZones"
+		if pageToken != "" {
+			apiUrl += "?pageToken=" + url.QueryEscape(pageToken)
+		}
+		r, err := http.NewRequest(http.MethodGet, apiUrl, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		var response struct {
+			ManagedZones  []gcpManagedZone `json:"managedZones"`
+			NextPageToken string           `json:"nextPageToken"`
+		}
+		if err := getProviderJSON(client, r, &response); err != nil {
+			return nil, err
+		}
+		zones = append(zones, response.ManagedZones...)
+		if response.NextPageToken == "" {
+			return zones, nil
+		}
+		pageToken = response.NextPageToken
+	}
+}
+
+var populateGCP = func(db *sql.DB) error {
+	log := logger.With("provider", "gcp")
+	accounts, err := readAccountsFile(configPath(gcpFile), 3, 3)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "syntax", "gcpId:project[,project...]:serviceAccountKeyFile", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting GCP Cloud DNS data:")
+	for _, dataFields := range accounts {
+		gcpId, projects, keyFile := dataFields[0], strings.Split(dataFields[1], ","), dataFields[2]
+		if !filepath.IsAbs(keyFile) {
+			keyFile = configPath(keyFile)
+		}
+		render.Info("-- gcpId: %s", gcpId)
+		log := providerLogger("gcp", gcpId)
+
+		serviceAccount, err := loadGCPServiceAccount(keyFile)
+		if err != nil {
+			log.Error("Unable to read service account key", "operation", "read_config", "error", err)
+			recordAccountSync("gcp", gcpId, 0, err)
+			continue
+		}
+		client, err := newProviderClient("gcp", gcpId)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("gcp", gcpId, 0, err)
+			continue
+		}
+		token, err := getGCPToken(client, serviceAccount)
+		if err != nil {
+			log.Error("Unable to get access token", "operation", "login", "error", err)
+			recordAccountSync("gcp", gcpId, 0, err)
+			continue
+		}
+
+		// Project is used as realId, private zones are flagged by gcp-private ISP
+		records := []domainRecord{}
+		for _, project := range projects {
+			var zones []gcpManagedZone
+			if zones, err = getGCPZones(client, token, project); err != nil {
+				log.Error("Unable to list managed zones", "operation", "list_zones", "project", project, "error", err)
+				break
+			}
+			for _, zone := range zones {
+				domain := strings.ToLower(strings.TrimSuffix(zone.DNSName, "."))
+				if err := checkDNS(domain); err != nil {
+					log.Warn("Skipping invalid zone name", "operation", "list_zones", "project", project, "zone", zone.DNSName, "error", err)
+					continue
+				}
+				isp := "gcp"
+				if zone.Visibility == "private" {
+					isp = "gcp-private"
+				}
+				records = append(records, domainRecord{ID: gcpId, RealID: project, ISP: isp, Domain: domain})
+			}
+		}
+		if err != nil {
+			recordAccountSync("gcp", gcpId, 0, err)
+			continue
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert managed zones", "operation", "insert", "error", err)
+			recordAccountSync("gcp", gcpId, 0, err)
+			continue
+		}
+		recordAccountSync("gcp", gcpId, len(records), nil)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test service account JWT exchange, pagination and private zones against a local Cloud DNS stand-in
+func TestPopulateGCP(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			// Assertion must be signed with service account key
+			parts := strings.Split(r.FormValue("assertion"), ".")
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			if !strings.Contains(string(claims), `"iss":"dns@example.iam.gserviceaccount.com"`) {
+				t.Errorf("Unexpected claims: %s", claims)
+			}
+			fmt.Fprint(w, `{"access_token":"gcpToken","expires_in":3599,"token_type":"Bearer"}`)
+		case r.Header.Get("Authorization") != "Bearer gcpToken":
+			http.Error(w, `{"error":{"code":401}}`, http.StatusUnauthorized)
+		case r.URL.Path == "/dns/v1/projects/web-prod/managedZones" && r.URL.Query().Get("pageToken") == "":
+			fmt.Fprint(w, `{"managedZones":[{"name":"example-com","dnsName":"example.com.","visibility":"public"}],"nextPageToken":"page2"}`)
+		case r.URL.Path == "/dns/v1/projects/web-prod/managedZones":
+			fmt.Fprint(w, `{"managedZones":[{"name":"internal","dnsName":"internal.example.com.","visibility":"private"}]}`)
+		case r.URL.Path == "/dns/v1/projects/web-dev/managedZones":
+			fmt.Fprint(w, `{"managedZones":[{"name":"dev","dnsName":"dev.example.net.","visibility":"public"}]}`)
+		default:
+			http.Error(w, `{"error":{"code":404}}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	gcpDNSEndpointOri := gcpDNSEndpoint
+	defer func() {
+		gcpDNSEndpoint = gcpDNSEndpointOri
+	}()
+	gcpDNSEndpoint = server.URL
+
+	serviceAccount, _ := json.Marshal(gcpServiceAccount{
+		ClientEmail: "dns@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    server.URL + "/token",
+	})
+	keyFile := writeTempFile(t, "dns.json", string(serviceAccount))
+	withTestConfig(t, gcpFile, "#gcpId:project[,project...]:serviceAccountKeyFile\nweb:web-prod,web-dev:"+keyFile+"\nmissing:web-prod:missing.json\n")
+
+	syncResults = nil
+	records, err := populateTestDb(t, populateGCP)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Expected 3 managed zones, but got: %+v %v", records, err)
+	}
+	for i, expected := range []domainRecord{
+		{ID: "web", RealID: "web-dev", ISP: "gcp", Domain: "dev.example.net"},
+		{ID: "web", RealID: "web-prod", ISP: "gcp", Domain: "example.com"},
+		{ID: "web", RealID: "web-prod", ISP: "gcp-private", Domain: "internal.example.com"},
+	} {
+		if records[i] != expected {
+			t.Errorf("Expected %+v, but got: %+v", expected, records[i])
+		}
+	}
+	if failed := failedAccounts(); len(failed) != 1 || failed[0].Account != "missing" {
+		t.Errorf("Expected missing key file account failed, but got: %+v", failed)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+// Hosting companies DNS zones: Hetzner, DigitalOcean and Linode, all of them use bearer API tokens
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Credentials files in configuration directory, one per line: id:apiToken
+var (
+	hetznerFile      = "hetzner.list"
+	digitalOceanFile = "digitalocean.list"
+	linodeFile       = "linode.list"
+)
+
+var (
+	hetznerEndpoint      = "https://api.hetzner.cloud"
+	digitalOceanEndpoint = "https://api.digitalocean.com"
+	linodeEndpoint       = "https://api.linode.com"
+)
+
+func bearerRequest(apiUrl, token string) (*http.Request, error) {
+	r, err := http.NewRequest(http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r, nil
+}
+
+// Get zones following next_page pagination
+var getHetznerZones = func(client *http.Client, token string) ([]string, error) {
+	zones := []string{}
+	for page := 1; page != 0; {
+		r, err := bearerRequest(hetznerEndpoint+"/v1/zones?per_page=100&page="+strconv.Itoa(page), token)
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Zones []struct {
+				Name string `json:"name"`
+			} `json:"zones"`
+			Meta struct {
+				Pagination struct {
+					NextPage int `json:"next_page"`
+				} `json:"pagination"`
+			} `json:"meta"`
+		}
+		if err := getProviderJSON(client, r, &response); err != nil {
+			return nil, err
+		}
+		for _, zone := range response.Zones {
+			zones = append(zones, zone.Name)
+		}
+		page = response.Meta.Pagination.NextPage
+	}
+	return zones, nil
+}
+
+// Get domains following links.pages.next URLs
+var getDigitalOceanZones = func(client *http.Client, token string) ([]string, error) {
+	zones := []string{}
+	apiUrl := digitalOceanEndpoint + "/v2/domains?per_page=200"
+	for apiUrl != "" {
+		r, err := bearerRequest(apiUrl, token)
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Domains []struct {
+				Name string `json:"name"`
+			} `json:"domains"`
+			Links struct {
+				Pages struct {
+					Next string `json:"next"`
+				} `json:"pages"`
+			} `json:"links"`
+		}
+		if err := getProviderJSON(client, r, &response); err != nil {
+			return nil, err
+		}
+		for _, domain := range response.Domains {
+			zones = append(zones, domain.Name)
+		}
+		apiUrl = response.Links.Pages.Next
+	}
+	return zones, nil
+}
+
+// Get domains until last page
+var getLinodeZones = func(client *http.Client, token string) ([]string, error) {
+	zones := []string{}
+	for page, pages := 1, 1; page <= pages; page++ {
+		query := url.Values{"page": {strconv.Itoa(page)}, "page_size": {"500"}}
+		r, err := bearerRequest(linodeEndpoint+"/v4/domains?"+query.Encode(), token)
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Data []struct {
+				Domain string `json:"domain"`
+			} `json:"data"`
+			Pages int `json:"pages"`
+		}
+		if err := getProviderJSON(client, r, &response); err != nil {
+			return nil, err
+		}
+		for _, domain := range response.Data {
+			zones = append(zones, domain.Domain)
+		}
+		pages = response.Pages
+	}
+	return zones, nil
+}
+
+// Insert zones of each account in file, provider name is used as ISP
+func populateBearerProvider(db *sql.DB, provider, file, title string, getZones func(client *http.Client, token string) ([]string, error)) error {
+	log := logger.With("provider", provider)
+	accounts, err := readAccountsFile(configPath(file), 2, 2)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "syntax", "id:apiToken", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting %s data:", title)
+	for _, dataFields := range accounts {
+		id, token := dataFields[0], dataFields[1]
+		render.Info("-- %sId: %s", provider, id)
+		log := providerLogger(provider, id)
+
+		client, err := newProviderClient(provider, id)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync(provider, id, 0, err)
+			continue
+		}
+		zones, err := getZones(client, token)
+		if err != nil {
+			log.Error("Unable to list zones", "operation", "list_zones", "error", err)
+			recordAccountSync(provider, id, 0, err)
+			continue
+		}
+
+		records := []domainRecord{}
+		for _, zone := range zones {
+			domain := strings.ToLower(strings.TrimSuffix(zone, "."))
+			if err := checkDNS(domain); err != nil {
+				log.Warn("Skipping invalid zone name", "operation", "list_zones", "zone", zone, "error", err)
+				continue
+			}
+			records = append(records, domainRecord{ID: id, RealID: id, ISP: provider, Domain: domain})
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert zones", "operation", "insert", "error", err)
+			recordAccountSync(provider, id, 0, err)
+			continue
+		}
+		recordAccountSync(provider, id, len(records), nil)
+	}
+	return nil
+}
+
+var populateHetzner = func(db *sql.DB) error {
+	return populateBearerProvider(db, "hetzner", hetznerFile, "Hetzner", getHetznerZones)
+}
+
+var populateDigitalOcean = func(db *sql.DB) error {
+	return populateBearerProvider(db, "digitalocean", digitalOceanFile, "DigitalOcean", getDigitalOceanZones)
+}
+
+var populateLinode = func(db *sql.DB) error {
+	return populateBearerProvider(db, "linode", linodeFile, "Linode", getLinodeZones)
+}
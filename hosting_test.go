@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test Hetzner, DigitalOcean and Linode pagination against a local stand-in for the three APIs
+func TestPopulateHosting(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token1" {
+			http.Error(w, `{"error":{"code":"unauthorized","message":"unable to authenticate"}}`, http.StatusUnauthorized)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		switch r.URL.Path {
+		case "/v1/zones":
+			if page == "1" {
+				fmt.Fprint(w, `{"zones":[{"id":1,"name":"hetzner.de"}],"meta":{"pagination":{"page":1,"per_page":100,"next_page":2,"last_page":2}}}`)
+			} else {
+				fmt.Fprint(w, `{"zones":[{"id":2,"name":"hetzner2.de"}],"meta":{"pagination":{"page":2,"per_page":100,"next_page":null,"last_page":2}}}`)
+			}
+		case "/v2/domains":
+			if page == "" {
+				fmt.Fprintf(w, `{"domains":[{"name":"ocean.io","ttl":1800}],"links":{"pages":{"next":"%s/v2/domains?page=2&per_page=200"}},"meta":{"total":2}}`, server.URL)
+			} else {
+				fmt.Fprint(w, `{"domains":[{"name":"Ocean2.io","ttl":1800}],"links":{},"meta":{"total":2}}`)
+			}
+		case "/v4/domains":
+			fmt.Fprintf(w, `{"data":[{"id":%s,"domain":"linode%s.org","type":"master"}],"page":%s,"pages":2,"results":2}`, page, page, page)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	hetznerEndpointOri := hetznerEndpoint
+	digitalOceanEndpointOri := digitalOceanEndpoint
+	linodeEndpointOri := linodeEndpoint
+	defer func() {
+		hetznerEndpoint = hetznerEndpointOri
+		digitalOceanEndpoint = digitalOceanEndpointOri
+		linodeEndpoint = linodeEndpointOri
+	}()
+	hetznerEndpoint = server.URL
+	digitalOceanEndpoint = server.URL
+	linodeEndpoint = server.URL
+
+	for _, test := range []struct {
+		file     string
+		populate func(db *sql.DB) error
+		isp      string
+		domains  []string
+	}{
+		{hetznerFile, populateHetzner, "hetzner", []string{"hetzner.de", "hetzner2.de"}},
+		{digitalOceanFile, populateDigitalOcean, "digitalocean", []string{"ocean.io", "ocean2.io"}},
+		{linodeFile, populateLinode, "linode", []string{"linode1.org", "linode2.org"}},
+	} {
+		withTestConfig(t, test.file, "#id:apiToken\nproject1:token1\nrevoked:token2\n")
+		syncResults = nil
+		records, err := populateTestDb(t, test.populate)
+		if err != nil || len(records) != len(test.domains) {
+			t.Fatalf("%s: expected %d zones, but got: %+v %v", test.isp, len(test.domains), records, err)
+		}
+		for i, domain := range test.domains {
+			if records[i] != (domainRecord{ID: "project1", RealID: "project1", ISP: test.isp, Domain: domain}) {
+				t.Errorf("%s: unexpected %s record: %+v", test.isp, domain, records[i])
+			}
+		}
+		if failed := failedAccounts(); len(failed) != 1 || failed[0].Provider != test.isp || failed[0].Account != "revoked" {
+			t.Errorf("%s: expected revoked account failed, but got: %+v", test.isp, failed)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+// Leveled structured logging, providers errors are logged with provider, account and operation attributes
+// so cron runs can be monitored parsing JSON output
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Log formats
+const (
+	logText = "text"
+	logJSON = "json"
+)
+
+var logger = newLogger(os.Stderr, logText, slog.LevelInfo)
+
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if format == logJSON {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// Get logger level from CLI flags: -verbose shows HTTP requests, -quiet only warnings and errors
+func logLevel(verbose, quiet bool) slog.Level {
+	switch {
+	case verbose:
+		return slog.LevelDebug
+	case quiet:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func checkLogFormat(format string) error {
+	if format != logText && format != logJSON {
+		return fmt.Errorf("Invalid log format %s, use %s or %s", format, logText, logJSON)
+	}
+	return nil
+}
+
+// Provider account logger, operation attribute is added in each message
+func providerLogger(provider, account string) *slog.Logger {
+	return logger.With("provider", provider, "account", account)
+}
+
+// Query parameters and headers whose values are never logged
+var secretNames = []string{"key", "secret", "pass", "token", "auth", "signature", "consumer", "apiuser", "cookie"}
+
+func isSecret(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretNames {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// URL without credentials nor secret query parameters
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("REDACTED")
+	}
+	query := redacted.Query()
+	for name := range query {
+		if isSecret(name) {
+			query.Set(name, "REDACTED")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
+
+func redactHeaders(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name := range header {
+		if isSecret(name) || strings.HasPrefix(strings.ToLower(name), "x-ovh-") {
+			redacted[name] = "REDACTED"
+		} else {
+			redacted[name] = header.Get(name)
+		}
+	}
+	return redacted
+}
+
+// HTTP transport logging requests/responses summaries in verbose mode, bodies are never logged
+type loggingTransport struct {
+	provider string
+	account  string
+	// nil uses http.DefaultTransport
+	next http.RoundTripper
+}
+
+func (t *loggingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if !logger.Enabled(r.Context(), slog.LevelDebug) {
+		return next.RoundTrip(r)
+	}
+
+	log := providerLogger(t.provider, t.account).With("operation", "http", "method", r.Method, "url", redactURL(r.URL))
+	log.Debug("HTTP request", "headers", redactHeaders(r.Header))
+	start := time.Now()
+	resp, err := next.RoundTrip(r)
+	if err != nil {
+		log.Debug("HTTP request failed", "duration", time.Since(start), "error", err)
+		return resp, err
+	}
+	log.Debug("HTTP response", "status", resp.StatusCode, "duration", time.Since(start), "length", resp.ContentLength)
+	return resp, nil
+}
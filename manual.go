@@ -0,0 +1,267 @@
+package main
+
+// Domains at registrars without API or under client-owned accounts, imported from CSV/YAML files into
+// manual_domains table, manual provider adds them to domain_list in each sync
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const manualProvider = "manual"
+
+// Manually maintained domain, realId defaults to account
+type manualDomain struct {
+	Domain  string `yaml:"domain"`
+	ISP     string `yaml:"isp"`
+	Account string `yaml:"account"`
+	RealID  string `yaml:"realId"`
+	Expires string `yaml:"expires"`
+}
+
+// Import source and its domains count
+type manualSource struct {
+	Source   string
+	Domains  int
+	Imported string
+}
+
+func createManualTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS manual_domains ( "domain" VARCHAR(100), "isp" VARCHAR(100), "id" VARCHAR(100), "realId" VARCHAR(100), "expires" VARCHAR(30) DEFAULT '', "source" VARCHAR(100), "imported" VARCHAR(30));`)
+	return err
+}
+
+// CSV files need a header with domain, isp and account columns, realId and expires ones are optional
+func readManualCSV(r io.Reader) ([]manualDomain, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("Missing CSV header: domain,isp,account[,realId][,expires]")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"domain", "isp", "account"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("Missing CSV %s column, header: domain,isp,account[,realId][,expires]", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	domains := []manualDomain{}
+	for _, record := range records[1:] {
+		domains = append(domains, manualDomain{
+			Domain:  field(record, "domain"),
+			ISP:     field(record, "isp"),
+			Account: field(record, "account"),
+			RealID:  field(record, "realId"),
+			Expires: field(record, "expires"),
+		})
+	}
+	return domains, nil
+}
+
+// YAML files contain a list of domain, isp, account, realId and expires mappings
+func readManualYAML(r io.Reader) ([]manualDomain, error) {
+	domains := []manualDomain{}
+	if err := yaml.NewDecoder(r).Decode(&domains); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// Read and validate manual domains file, format is taken from its extension
+func loadManualFile(file string) ([]manualDomain, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var domains []manualDomain
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		domains, err = readManualCSV(f)
+	case ".yaml", ".yml":
+		domains, err = readManualYAML(f)
+	default:
+		return nil, fmt.Errorf("Unsupported file %s, use .csv, .yaml or .yml files", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	for i := range domains {
+		domain := &domains[i]
+		domain.Domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain.Domain), "."))
+		if len(domain.Domain) >= 100 || checkDNS(domain.Domain) != nil {
+			return nil, fmt.Errorf("%s entry %d: invalid domain %q", file, i+1, domain.Domain)
+		}
+		if domain.ISP == "" || domain.Account == "" {
+			return nil, fmt.Errorf("%s entry %d: missing isp or account", file, i+1)
+		}
+		if domain.RealID == "" {
+			domain.RealID = domain.Account
+		}
+		if domain.Expires != "" {
+			if _, err := time.Parse(expiresFormat, domain.Expires); err != nil {
+				return nil, fmt.Errorf("%s entry %d: invalid expires %q, format: %s", file, i+1, domain.Expires, expiresFormat)
+			}
+		}
+	}
+	return domains, nil
+}
+
+// Get import source name from file name
+func manualSourceName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+// Replace source domains, domain_list is updated too so they are found before next sync
+func importManualDomains(db *sql.DB, source string, domains []manualDomain) error {
+	if err := createManualTable(db); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"manual_domains", "domain_list"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE source = ?", source); err != nil {
+			return err
+		}
+	}
+	imported := time.Now().UTC().Format(time.RFC3339)
+	for _, domain := range domains {
+		if _, err := tx.Exec("INSERT INTO manual_domains (domain, isp, id, realId, expires, source, imported) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			domain.Domain, domain.ISP, domain.Account, domain.RealID, domain.Expires, source, imported); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO domain_list (id, realId, isp, domain, expires, source) SELECT id, realId, isp, domain, expires, source FROM manual_domains WHERE source = ?", source); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Remove source domains, returns removed domains count
+func removeManualSource(db *sql.DB, source string) (int64, error) {
+	if err := createManualTable(db); err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM manual_domains WHERE source = ?", source)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM domain_list WHERE source = ?", source); err != nil {
+		return 0, err
+	}
+	removed, _ := result.RowsAffected()
+	return removed, tx.Commit()
+}
+
+func listManualSources(db *sql.DB) ([]manualSource, error) {
+	if err := createManualTable(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT source, COUNT(*), MAX(imported) FROM manual_domains GROUP BY source ORDER BY source")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []manualSource{}
+	for rows.Next() {
+		var source manualSource
+		if err := rows.Scan(&source.Source, &source.Domains, &source.Imported); err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
+
+// Add imported domains kept in mainDbFile to regenerated DB, nothing to do before first sync
+var populateManual = func(db *sql.DB, mainDbFile string) error {
+	if !checkFileExists(mainDbFile) {
+		return nil
+	}
+	mainDb, err := sql.Open("sqlite3", mainDbFile)
+	if err != nil {
+		return err
+	}
+	defer mainDb.Close()
+
+	// Nothing imported yet
+	var tables int
+	if err := mainDb.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='manual_domains'").Scan(&tables); err != nil || tables == 0 {
+		return err
+	}
+	sources, err := listManualSources(mainDb)
+	if err != nil {
+		return err
+	}
+	for _, source := range sources {
+		log := providerLogger(manualProvider, source.Source)
+		rows, err := mainDb.Query("SELECT id, realId, isp, domain, expires FROM manual_domains WHERE source = ?", source.Source)
+		if err != nil {
+			log.Error("Unable to read imported domains", "operation", "list_domains", "error", err)
+			recordAccountSync(manualProvider, source.Source, 0, err)
+			continue
+		}
+		var records []domainRecord
+		for rows.Next() {
+			var record domainRecord
+			if err = rows.Scan(&record.ID, &record.RealID, &record.ISP, &record.Domain, &record.Expires); err != nil {
+				break
+			}
+			record.Source = source.Source
+			records = append(records, record)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		rows.Close()
+		if err != nil {
+			log.Error("Unable to read imported domains", "operation", "list_domains", "error", err)
+			recordAccountSync(manualProvider, source.Source, 0, err)
+			continue
+		}
+
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert imported domains", "operation", "insert", "error", err)
+			recordAccountSync(manualProvider, source.Source, 0, err)
+			continue
+		}
+		recordAccountSync(manualProvider, source.Source, len(records), nil)
+	}
+	return nil
+}
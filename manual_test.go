@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Write file in a temporary directory returning its path
+func writeTempFile(t *testing.T, name, content string) string {
+	file := t.TempDir() + "/" + name
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// Test CSV and YAML manual domains files
+func TestLoadManualFile(t *testing.T) {
+	domains, err := loadManualFile(writeTempFile(t, "clients.csv", "# Client owned domains\naccount,isp,domain,expires\nclient1,namecheap,Client1.com.,2030-01-01\nclient2,gandi,client2.es,\n"))
+	if err != nil || len(domains) != 2 {
+		t.Fatalf("Expected 2 domains, but got: %+v %v", domains, err)
+	}
+	if domains[0] != (manualDomain{Domain: "client1.com", ISP: "namecheap", Account: "client1", RealID: "client1", Expires: "2030-01-01"}) {
+		t.Errorf("Unexpected CSV domain: %+v", domains[0])
+	}
+
+	domains, err = loadManualFile(writeTempFile(t, "registrar.yaml", "- domain: example.org\n  isp: registrar\n  account: legacy\n  realId: '1234'\n"))
+	if err != nil || len(domains) != 1 || domains[0].RealID != "1234" || domains[0].Domain != "example.org" {
+		t.Errorf("Expected example.org YAML domain, but got: %+v %v", domains, err)
+	}
+
+	for name, content := range map[string]string{
+		"noAccount.csv":  "domain,isp\nexample.com,registrar\n",
+		"badDomain.csv":  "domain,isp,account\nbad_domain.com,registrar,legacy\n",
+		"badExpires.yml": "- {domain: example.com, isp: registrar, account: legacy, expires: 01/01/2030}\n",
+		"noIsp.yml":      "- {domain: example.com, account: legacy}\n",
+		"domains.txt":    "example.com\n",
+	} {
+		if _, err := loadManualFile(writeTempFile(t, name, content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// Test imported domains are found, flagged and kept across syncs
+func TestImportCommand(t *testing.T) {
+	withTestDb(t)
+	file := writeTempFile(t, "clients.csv", "domain,isp,account\nclient.com,namecheap,client1\n")
+
+	if exitCode, out := captureRun(t, "import", file); exitCode != exitOK || !strings.Contains(out, "clients: 1 domains imported") {
+		t.Fatalf("Expected import, but got: %d %s", exitCode, out)
+	}
+	if exitCode, out := captureRun(t, "search", "client.com"); exitCode != exitFound || !strings.Contains(out, "namecheap / client1 (manual: clients)") {
+		t.Errorf("Expected manual domain found, but got: %d %s", exitCode, out)
+	}
+
+	mockProvidersSync(t, false)
+	regenerateDb(dbFile)
+	if exitCode, out := captureRun(t, "search", "-details", "-max-age", "0", "client.com"); exitCode != exitFound || !strings.Contains(out, "SOURCE: clients, manually maintained") {
+		t.Errorf("Expected manual domain kept after sync, but got: %d %s", exitCode, out)
+	}
+
+	// Importing again replaces source domains
+	file = writeTempFile(t, "clients.yaml", "- {domain: other.com, isp: namecheap, account: client1}\n")
+	captureRun(t, "import", "-source", "clients", file)
+	if exitCode, out := captureRun(t, "import", "-list"); exitCode != exitOK || !strings.Contains(out, "clients: 1 domains") {
+		t.Errorf("Expected clients source, but got: %d %s", exitCode, out)
+	}
+	if exitCode, _ := captureRun(t, "search", "client.com"); exitCode != exitNotFound {
+		t.Errorf("Expected replaced domain not found, but got: %d", exitCode)
+	}
+
+	if exitCode, out := captureRun(t, "import", "-remove", "clients"); exitCode != exitOK || !strings.Contains(out, "1 domains removed") {
+		t.Errorf("Expected removed source, but got: %d %s", exitCode, out)
+	}
+	if exitCode, _ := captureRun(t, "search", "other.com"); exitCode != exitNotFound {
+		t.Errorf("Expected removed domain not found, but got: %d", exitCode)
+	}
+	if exitCode, _ := captureRun(t, "import", "-list", file); exitCode != exitUsage {
+		t.Errorf("Expected usage error, but got: %d", exitCode)
+	}
+}
+
+// Test regenerated DB imported domains are taken from it, not from -db one
+func TestPopulateManualDbFile(t *testing.T) {
+	db := withTestDb(t)
+	file := writeTempFile(t, "clients.csv", "domain,isp,account\nclient.com,namecheap,client1\n")
+	if exitCode, out := captureRun(t, "import", file); exitCode != exitOK {
+		t.Fatalf("Expected import, but got: %d %s", exitCode, out)
+	}
+	mockProvidersSync(t, false)
+
+	regeneratedDbFile := dbFile
+	dbFile = t.TempDir() + "/other.db"
+	regenerateDb(regeneratedDbFile)
+	if records, _ := lookupDomain(db, "client.com"); len(records) != 1 {
+		t.Errorf("Expected client.com kept, but got: %+v", records)
+	}
+}
+
+// Test failed manual sources keep their previous domains, apart from provider accounts ones
+func TestManualSourceFailed(t *testing.T) {
+	db := withTestDb(t)
+	file := writeTempFile(t, "clients.csv", "domain,isp,account\nclient.com,namecheap,client1\nlegacy.com,ovh,ovhId2\n")
+	if exitCode, out := captureRun(t, "import", file); exitCode != exitOK {
+		t.Fatalf("Expected import, but got: %d %s", exitCode, out)
+	}
+	mockProvidersSync(t, false)
+	regenerateDb(dbFile)
+
+	populateManualOri := populateManual
+	defer func() {
+		populateManual = populateManualOri
+	}()
+	populateManual = func(db *sql.DB, mainDbFile string) error {
+		if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain, source) VALUES ("client1", "client1", "namecheap", "partial.com", "clients")`); err != nil {
+			return err
+		}
+		recordAccountSync(manualProvider, "clients", 0, errors.New("database is locked"))
+		return nil
+	}
+	mockProvidersSync(t, true)
+	regenerateDb(dbFile)
+
+	for domain, holders := range map[string]int{"client.com": 1, "legacy.com": 1, "partial.com": 0, "second.com": 1, "zone.com": 1} {
+		if records, _ := lookupDomain(db, domain); len(records) != holders {
+			t.Errorf("Expected %s held by %d accounts, but got: %+v", domain, holders, records)
+		}
+	}
+}
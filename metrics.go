@@ -0,0 +1,295 @@
+package main
+
+// Prometheus metrics in text exposition format, served in /metrics by serve/daemon commands
+// or written by sync command to a node_exporter textfile collector file
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lookup results
+const (
+	lookupFound    = "found"
+	lookupNotFound = "not_found"
+	lookupInvalid  = "invalid"
+	lookupError    = "error"
+)
+
+// Lookup latency histogram buckets, seconds
+var lookupDurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type lookupHistogram struct {
+	// Non cumulative counts, last one is +Inf
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// API lookups counts per endpoint and result and latencies per endpoint
+type lookupMetrics struct {
+	mu        sync.Mutex
+	counts    map[[2]string]uint64
+	durations map[string]*lookupHistogram
+}
+
+func newLookupMetrics() *lookupMetrics {
+	return &lookupMetrics{counts: map[[2]string]uint64{}, durations: map[string]*lookupHistogram{}}
+}
+
+func (m *lookupMetrics) observe(endpoint, result string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[[2]string{endpoint, result}]++
+
+	histogram, ok := m.durations[endpoint]
+	if !ok {
+		histogram = &lookupHistogram{buckets: make([]uint64, len(lookupDurationBuckets)+1)}
+		m.durations[endpoint] = histogram
+	}
+	seconds := duration.Seconds()
+	bucket := sort.SearchFloat64s(lookupDurationBuckets, seconds)
+	histogram.buckets[bucket]++
+	histogram.sum += seconds
+	histogram.count++
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Metric sample line, labels are name/value pairs
+func writeSample(w io.Writer, name string, value float64, labels ...string) {
+	pairs := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+escapeLabelValue(labels[i+1])+`"`)
+	}
+	if len(pairs) > 0 {
+		name += "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (m *lookupMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeMetricHeader(w, "domainsearcher_lookups_total", "counter", "API domain lookups by endpoint and result.")
+	keys := [][2]string{}
+	for key := range m.counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i][0]+" "+keys[i][1] < keys[j][0]+" "+keys[j][1]
+	})
+	for _, key := range keys {
+		writeSample(w, "domainsearcher_lookups_total", float64(m.counts[key]), "endpoint", key[0], "result", key[1])
+	}
+
+	writeMetricHeader(w, "domainsearcher_lookup_duration_seconds", "histogram", "API domain lookups latency by endpoint.")
+	endpoints := []string{}
+	for endpoint := range m.durations {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		histogram := m.durations[endpoint]
+		var cumulative uint64
+		for i, le := range lookupDurationBuckets {
+			cumulative += histogram.buckets[i]
+			writeSample(w, "domainsearcher_lookup_duration_seconds_bucket", float64(cumulative), "endpoint", endpoint, "le", strconv.FormatFloat(le, 'g', -1, 64))
+		}
+		writeSample(w, "domainsearcher_lookup_duration_seconds_bucket", float64(histogram.count), "endpoint", endpoint, "le", "+Inf")
+		writeSample(w, "domainsearcher_lookup_duration_seconds_sum", histogram.sum, "endpoint", endpoint)
+		writeSample(w, "domainsearcher_lookup_duration_seconds_count", float64(histogram.count), "endpoint", endpoint)
+	}
+}
+
+func parseSyncTime(value string) (float64, bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, false
+	}
+	return float64(t.Unix()), true
+}
+
+// Write inventory and sync metrics from DB, lookups ones too when given
+func writeMetrics(w io.Writer, db *sql.DB, lookups *lookupMetrics) error {
+	rows, err := db.Query("SELECT isp, id, COUNT(*) FROM domain_list GROUP BY isp, id ORDER BY isp, id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	writeMetricHeader(w, "domainsearcher_domains", "gauge", "Domains in inventory by ISP and account.")
+	for rows.Next() {
+		var isp, account string
+		var domains int
+		if err := rows.Scan(&isp, &account, &domains); err != nil {
+			return err
+		}
+		writeSample(w, "domainsearcher_domains", float64(domains), "isp", isp, "account", account)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Read only, sync tables are created by sync: DB never synced by this version has no sync metrics
+	var syncTables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name IN ('sync_runs', 'sync_accounts')").Scan(&syncTables); err != nil {
+		return err
+	}
+	if syncTables == 2 {
+		if err := writeSyncMetrics(w, db); err != nil {
+			return err
+		}
+	}
+
+	if lookups != nil {
+		lookups.write(w)
+	}
+	return nil
+}
+
+// Write last sync run and per provider account sync metrics
+func writeSyncMetrics(w io.Writer, db *sql.DB) error {
+	var started, finished string
+	var success bool
+	err := db.QueryRow("SELECT started, finished, success FROM sync_runs ORDER BY id DESC LIMIT 1").Scan(&started, &finished, &success)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		startedAt, _ := time.Parse(time.RFC3339, started)
+		finishedAt, _ := time.Parse(time.RFC3339, finished)
+		writeMetricHeader(w, "domainsearcher_sync_duration_seconds", "gauge", "Last sync run duration.")
+		writeSample(w, "domainsearcher_sync_duration_seconds", finishedAt.Sub(startedAt).Seconds())
+		writeMetricHeader(w, "domainsearcher_sync_success", "gauge", "Whether all providers accounts succeeded in last sync run.")
+		writeSample(w, "domainsearcher_sync_success", boolMetric(success))
+	}
+	err = db.QueryRow("SELECT finished FROM sync_runs WHERE success ORDER BY id DESC LIMIT 1").Scan(&finished)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil {
+		if timestamp, ok := parseSyncTime(finished); ok {
+			writeMetricHeader(w, "domainsearcher_last_successful_sync_timestamp_seconds", "gauge", "Last sync run in which all providers accounts succeeded.")
+			writeSample(w, "domainsearcher_last_successful_sync_timestamp_seconds", timestamp)
+		}
+	}
+
+	// Per provider and account, empty account is the provider itself: configuration file errors for example
+	// API errors count, sync_accounts tables created by previous versions lack it
+	errorsColumn := "errors"
+	var columns int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('sync_accounts') WHERE name = 'errors'").Scan(&columns); err != nil {
+		return err
+	}
+	if columns == 0 {
+		errorsColumn = "0"
+	}
+	rows, err := db.Query("SELECT provider, account, last_success, last_error, " + errorsColumn + " FROM sync_accounts ORDER BY provider, account")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	type accountMetrics struct {
+		provider, account, lastSuccess string
+		up                             bool
+		errors                         int
+	}
+	accounts := []accountMetrics{}
+	for rows.Next() {
+		var account accountMetrics
+		var lastError string
+		if err := rows.Scan(&account.provider, &account.account, &account.lastSuccess, &lastError, &account.errors); err != nil {
+			return err
+		}
+		account.up = lastError == ""
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	writeMetricHeader(w, "domainsearcher_sync_account_up", "gauge", "Whether provider account succeeded in last sync run.")
+	for _, account := range accounts {
+		writeSample(w, "domainsearcher_sync_account_up", boolMetric(account.up), "provider", account.provider, "account", account.account)
+	}
+	writeMetricHeader(w, "domainsearcher_sync_account_errors_total", "counter", "Provider account API errors in sync runs.")
+	for _, account := range accounts {
+		writeSample(w, "domainsearcher_sync_account_errors_total", float64(account.errors), "provider", account.provider, "account", account.account)
+	}
+	writeMetricHeader(w, "domainsearcher_sync_account_last_success_timestamp_seconds", "gauge", "Provider account last successful sync.")
+	for _, account := range accounts {
+		if timestamp, ok := parseSyncTime(account.lastSuccess); ok {
+			writeSample(w, "domainsearcher_sync_account_last_success_timestamp_seconds", timestamp, "provider", account.provider, "account", account.account)
+		}
+	}
+	return nil
+}
+
+func boolMetric(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// Write DB metrics to node_exporter textfile collector file, it is replaced atomically so partial files are never read
+func writeMetricsFile(dbFile, metricsFile string) error {
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tmpFile := metricsFile + ".tmp"
+	file, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile)
+	if err := writeMetrics(file, db, nil); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, metricsFile)
+}
+
+// GET /metrics: Prometheus metrics, they include all accounts so restricted tokens are rejected
+func (s *apiServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if token := contextToken(r.Context()); token != nil && (len(token.ISPs) > 0 || len(token.Accounts) > 0) {
+		writeJSONError(w, http.StatusForbidden, "Restricted tokens can't read metrics")
+		return
+	}
+	db, err := s.database()
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "api_metrics", "db", s.dbFile, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+
+	var metrics strings.Builder
+	if err := writeMetrics(&metrics, db, s.lookups); err != nil {
+		logger.Error("Unable to get metrics", "operation", "api_metrics", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, metrics.String())
+}
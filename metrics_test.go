@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test lookups counts and latency histogram
+func TestLookupMetrics(t *testing.T) {
+	metrics := newLookupMetrics()
+	metrics.observe("domain", lookupFound, 3*time.Millisecond)
+	metrics.observe("domain", lookupFound, 20*time.Second)
+	metrics.observe("domain", lookupNotFound, time.Millisecond)
+
+	var out strings.Builder
+	metrics.write(&out)
+	for _, expected := range []string{
+		`domainsearcher_lookups_total{endpoint="domain",result="found"} 2`,
+		`domainsearcher_lookups_total{endpoint="domain",result="not_found"} 1`,
+		`domainsearcher_lookup_duration_seconds_bucket{endpoint="domain",le="0.001"} 1`,
+		`domainsearcher_lookup_duration_seconds_bucket{endpoint="domain",le="0.005"} 2`,
+		`domainsearcher_lookup_duration_seconds_bucket{endpoint="domain",le="10"} 2`,
+		`domainsearcher_lookup_duration_seconds_bucket{endpoint="domain",le="+Inf"} 3`,
+		`domainsearcher_lookup_duration_seconds_count{endpoint="domain"} 3`,
+	} {
+		if !strings.Contains(out.String(), expected+"\n") {
+			t.Errorf("Expected %s, but got: %s", expected, out.String())
+		}
+	}
+
+	var labels strings.Builder
+	writeSample(&labels, "metric", 1, "account", "a\"b\\c")
+	if labels.String() != `metric{account="a\"b\\c"} 1`+"\n" {
+		t.Errorf("Expected escaped label value, but got: %s", labels.String())
+	}
+}
+
+// Test /metrics endpoint after a sync with a failed account
+func TestAPIMetrics(t *testing.T) {
+	db := withTestDb(t)
+	mockProvidersSync(t, true)
+	regenerateDb(dbFile)
+	regenerateDb(dbFile)
+
+	server := newAPIServer(dbFile)
+	defer server.Close()
+	handler := server.handler()
+	fullToken, _ := addToken(db, "prometheus", nil, nil)
+	ovhToken, _ := addToken(db, "ovhOnly", []string{"ovh"}, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/domains/new.com", nil)
+	r.Header.Set("Authorization", "Bearer "+fullToken)
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	for token, status := range map[string]int{"": http.StatusUnauthorized, ovhToken: http.StatusForbidden, fullToken: http.StatusOK} {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		r.Header.Set("X-API-Key", token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != status {
+			t.Errorf("Expected status %d, but got: %d", status, w.Code)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+			t.Errorf("Expected Prometheus text format, but got: %s", w.Header().Get("Content-Type"))
+		}
+		for _, expected := range []string{
+			`domainsearcher_domains{isp="ovh",account="ovhId1"} 1`,
+			`domainsearcher_sync_success 0`,
+			`domainsearcher_sync_account_up{provider="ovh",account="ovhId2"} 0`,
+			`domainsearcher_sync_account_up{provider="ovh",account="ovhId1"} 1`,
+			`domainsearcher_sync_account_errors_total{provider="ovh",account="ovhId2"} 2`,
+			`domainsearcher_sync_account_errors_total{provider="dondominio",account=""} 2`,
+			`domainsearcher_lookups_total{endpoint="domain",result="found"} 1`,
+			"# TYPE domainsearcher_lookup_duration_seconds histogram",
+		} {
+			if !strings.Contains(w.Body.String(), expected+"\n") {
+				t.Errorf("Expected %s, but got: %s", expected, w.Body.String())
+			}
+		}
+		// Never fully synced
+		if strings.Contains(w.Body.String(), "domainsearcher_last_successful_sync_timestamp_seconds") {
+			t.Errorf("Expected no last successful sync, but got: %s", w.Body.String())
+		}
+	}
+}
+
+// Test sync command writes textfile collector file, also when some provider fails
+func TestSyncMetricsFile(t *testing.T) {
+	withTestDb(t)
+	mockProvidersSync(t, false)
+	metricsFile := t.TempDir() + "/domainsearcher.prom"
+
+	if exitCode, out := captureRun(t, "sync", "-quiet", "-metrics-file", metricsFile); exitCode != exitError {
+		t.Errorf("Expected failed sync due to dondominio error, but got: %d %s", exitCode, out)
+	}
+	metrics, err := os.ReadFile(metricsFile)
+	if err != nil {
+		t.Fatalf("Expected metrics file, but got: %v", err)
+	}
+	for _, expected := range []string{
+		`domainsearcher_domains{isp="ovh",account="ovhId2"} 1`,
+		`domainsearcher_sync_account_up{provider="ovh",account="ovhId2"} 1`,
+		`domainsearcher_sync_account_up{provider="dondominio",account=""} 0`,
+		"domainsearcher_sync_duration_seconds ",
+	} {
+		if !strings.Contains(string(metrics), expected) {
+			t.Errorf("Expected %s, but got: %s", expected, metrics)
+		}
+	}
+	// Lookups metrics are only served by serve/daemon commands
+	if strings.Contains(string(metrics), "domainsearcher_lookups_total") {
+		t.Errorf("Expected no lookups metrics, but got: %s", metrics)
+	}
+	if _, err := os.Stat(metricsFile + ".tmp"); err == nil {
+		t.Errorf("Expected temporary metrics file removed")
+	}
+}
+
+// Test metrics of DB never synced by this version, sync tables aren't created
+func TestMetricsWithoutSyncTables(t *testing.T) {
+	db := withTestDb(t)
+
+	var out strings.Builder
+	if err := writeMetrics(&out, db, nil); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !strings.Contains(out.String(), `domainsearcher_domains{isp="ovh",account="1"} 1`) || strings.Contains(out.String(), "domainsearcher_sync_") {
+		t.Errorf("Expected only domains metrics, but got: %s", out.String())
+	}
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name LIKE 'sync_%'").Scan(&tables)
+	if tables != 0 {
+		t.Errorf("Expected no sync tables created, but got: %d", tables)
+	}
+
+	// Sync tables created by previous versions lack errors column
+	if _, err := db.Exec(`CREATE TABLE sync_accounts ( "provider" VARCHAR(100), "account" VARCHAR(100), "last_attempt" VARCHAR(30), "last_success" VARCHAR(30) DEFAULT '', "last_error" TEXT DEFAULT '', "domains" INTEGER DEFAULT 0);
+		CREATE TABLE sync_runs ( "id" INTEGER PRIMARY KEY AUTOINCREMENT, "started" VARCHAR(30), "finished" VARCHAR(30), "success" INTEGER, "replaced" INTEGER, "domains" INTEGER);
+		INSERT INTO sync_accounts (provider, account, last_attempt) VALUES ("ovh", "1", "2024-01-01T00:00:00Z")`); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := writeMetrics(&out, db, nil); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !strings.Contains(out.String(), `domainsearcher_sync_account_errors_total{provider="ovh",account="1"} 0`) {
+		t.Errorf("Expected ovh account errors, but got: %s", out.String())
+	}
+}
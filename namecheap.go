@@ -0,0 +1,136 @@
+package main
+
+// Namecheap registered domains, XML API requires client IP whitelisting: use a proxy from an allowed system if required
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Credentials file in configuration directory, one per line: namecheapId:apiUser:apiKey:clientIp[:userName]
+// clientIp is the whitelisted IP the API is reached from, proxy one when proxies are used
+var namecheapFile = "namecheap.list"
+
+var namecheapEndpoint = "https://api.namecheap.com/xml.response"
+
+const namecheapPageSize = 100
+
+type namecheapDomain struct {
+	Name    string `xml:"Name,attr"`
+	Expires string `xml:"Expires,attr"`
+}
+
+type namecheapResponse struct {
+	Status string `xml:"Status,attr"`
+	Errors []struct {
+		Number  string `xml:"Number,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"Errors>Error"`
+	Domains    []namecheapDomain `xml:"CommandResponse>DomainGetListResult>Domain"`
+	TotalItems int               `xml:"CommandResponse>Paging>TotalItems"`
+}
+
+// Get all domains, API errors are returned with their number and message
+var getNamecheapDomains = func(client *http.Client, apiUser, apiKey, userName, clientIp string) ([]namecheapDomain, error) {
+	domains := []namecheapDomain{}
+	for page := 1; ; page++ {
+		query := url.Values{
+			"ApiUser":  {apiUser},
+			"ApiKey":   {apiKey},
+			"UserName": {userName},
+			"ClientIp": {clientIp},
+			"Command":  {"namecheap.domains.getList"},
+			"Page":     {strconv.Itoa(page)},
+			"PageSize": {strconv.Itoa(namecheapPageSize)},
+		}
+		r, err := http.NewRequest(http.MethodGet, namecheapEndpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := fetchProviderResponse(client, r)
+		if err != nil {
+			return nil, err
+		}
+
+		var response namecheapResponse
+		if err := xml.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if response.Status != "OK" {
+			if len(response.Errors) > 0 {
+				return nil, fmt.Errorf("API error %s: %s", response.Errors[0].Number, strings.TrimSpace(response.Errors[0].Message))
+			}
+			return nil, fmt.Errorf("API error, status: %s", response.Status)
+		}
+		domains = append(domains, response.Domains...)
+		if len(response.Domains) == 0 || page*namecheapPageSize >= response.TotalItems {
+			return domains, nil
+		}
+	}
+}
+
+var populateNamecheap = func(db *sql.DB) error {
+	log := logger.With("provider", "namecheap")
+	accounts, err := readAccountsFile(configPath(namecheapFile), 4, 5)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "syntax", "namecheapId:apiUser:apiKey:clientIp[:userName]", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting Namecheap data:")
+	for _, dataFields := range accounts {
+		namecheapId, apiUser, apiKey, clientIp := dataFields[0], dataFields[1], dataFields[2], dataFields[3]
+		// userName defaults to apiUser, it is used as realId
+		userName := accountField(dataFields, 4)
+		if userName == "" {
+			userName = apiUser
+		}
+		render.Info("-- namecheapId: %s", namecheapId)
+		log := providerLogger("namecheap", namecheapId)
+
+		client, err := newProviderClient("namecheap", namecheapId)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("namecheap", namecheapId, 0, err)
+			continue
+		}
+		domains, err := getNamecheapDomains(client, apiUser, apiKey, userName, clientIp)
+		if err != nil {
+			log.Error("Unable to list domains", "operation", "list_domains", "error", err)
+			recordAccountSync("namecheap", namecheapId, 0, err)
+			continue
+		}
+
+		records := []domainRecord{}
+		for _, domain := range domains {
+			name := strings.ToLower(strings.TrimSuffix(domain.Name, "."))
+			if err := checkDNS(name); err != nil {
+				log.Warn("Skipping invalid domain name", "operation", "list_domains", "domain", domain.Name, "error", err)
+				continue
+			}
+			record := domainRecord{ID: namecheapId, RealID: userName, ISP: "namecheap", Domain: name}
+			// Expiration dates are MM/DD/YYYY
+			if expires, err := time.Parse("01/02/2006", domain.Expires); err == nil {
+				record.Expires = expires.Format(expiresFormat)
+			}
+			records = append(records, record)
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert domains", "operation", "insert", "error", err)
+			recordAccountSync("namecheap", namecheapId, 0, err)
+			continue
+		}
+		recordAccountSync("namecheap", namecheapId, len(records), nil)
+	}
+	return nil
+}
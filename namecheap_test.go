@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test pagination and API errors against a Namecheap stand-in reached through the account proxy
+func TestPopulateNamecheap(t *testing.T) {
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Proxied requests contain the absolute URL
+		if r.URL.Host != "api.namecheap.test" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("ClientIp") != "192.0.2.10" {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><ApiResponse Status="ERROR"><Errors><Error Number="1011150">Invalid request IP: 198.51.100.1</Error></Errors></ApiResponse>`)
+			return
+		}
+		if query.Get("UserName") != "owner" || query.Get("Command") != "namecheap.domains.getList" {
+			t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+		}
+		if query.Get("Page") == "1" {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><ApiResponse Status="OK"><Errors /><CommandResponse Type="namecheap.domains.getList"><DomainGetListResult>`)
+			for i := 0; i < namecheapPageSize; i++ {
+				fmt.Fprintf(w, `<Domain ID="%d" Name="Domain%d.com" User="owner" Created="02/15/2020" Expires="02/15/2030" IsExpired="false" />`, i, i)
+			}
+			fmt.Fprintf(w, `</DomainGetListResult><Paging><TotalItems>%d</TotalItems><CurrentPage>1</CurrentPage><PageSize>100</PageSize></Paging></CommandResponse></ApiResponse>`, namecheapPageSize+1)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><ApiResponse Status="OK"><CommandResponse><DomainGetListResult><Domain ID="999" Name="last.net" Expires="12/31/2031" /><Domain ID="1000" Name="bad_name.net" Expires="12/31/2031" /></DomainGetListResult><Paging><TotalItems>%d</TotalItems></Paging></CommandResponse></ApiResponse>`, namecheapPageSize+1)
+	}))
+	defer proxyServer.Close()
+
+	namecheapEndpointOri := namecheapEndpoint
+	proxiesOri := proxies
+	defer func() {
+		namecheapEndpoint = namecheapEndpointOri
+		proxies = proxiesOri
+	}()
+	namecheapEndpoint = "http://api.namecheap.test/xml.response"
+	proxies = proxyConfig{"namecheap": proxyServer.URL}
+
+	withTestConfig(t, namecheapFile, "#namecheapId:apiUser:apiKey:clientIp[:userName]\nmain:owner:key:192.0.2.10\nnotWhitelisted:owner:key:198.51.100.1\n")
+	syncResults = nil
+	records, err := populateTestDb(t, populateNamecheap)
+	if err != nil || len(records) != namecheapPageSize+1 {
+		t.Fatalf("Expected %d domains, but got: %d %v", namecheapPageSize+1, len(records), err)
+	}
+	if records[0] != (domainRecord{ID: "main", RealID: "owner", ISP: "namecheap", Domain: "domain0.com", Expires: "2030-02-15"}) {
+		t.Errorf("Unexpected first domain: %+v", records[0])
+	}
+	if last := records[len(records)-1]; last.Domain != "last.net" || last.Expires != "2031-12-31" {
+		t.Errorf("Unexpected last domain: %+v", last)
+	}
+	if failed := failedAccounts(); len(failed) != 1 || !strings.Contains(failed[0].Err.Error(), "API error 1011150: Invalid request IP") {
+		t.Errorf("Expected not whitelisted account failed, but got: %+v", failed)
+	}
+}
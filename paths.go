@@ -0,0 +1,62 @@
+package main
+
+// DB and configuration files location, independent of working directory so it can be run from cron
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const appName = "domainSearcher"
+
+// Configuration files directory and DB file:
+// -config/-db flags > DOMAINSEARCHER_CONFIG/DOMAINSEARCHER_DB environment variables > XDG directories
+var (
+	configDir = filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), appName)
+	dbFile    = filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), appName, "domain_list.db")
+)
+
+// Get XDG base directory, fallbackDir is relative to user home directory
+func xdgDir(env, fallbackDir string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		// No home directory, use working directory as last resort
+		return fallbackDir
+	}
+	return filepath.Join(home, fallbackDir)
+}
+
+// Apply environment variables and flags values, empty flags keep current location
+func resolvePaths(dbFlag, configFlag string) error {
+	if dir := os.Getenv("DOMAINSEARCHER_CONFIG"); dir != "" {
+		configDir = dir
+	}
+	if file := os.Getenv("DOMAINSEARCHER_DB"); file != "" {
+		dbFile = file
+	}
+	if configFlag != "" {
+		configDir = configFlag
+	}
+	if dbFlag != "" {
+		dbFile = dbFlag
+	}
+
+	var err error
+	if configDir, err = filepath.Abs(configDir); err != nil {
+		return err
+	}
+	if dbFile, err = filepath.Abs(dbFile); err != nil {
+		return err
+	}
+
+	// DB directory is created on demand, it contains accounts information so keep it private
+	return os.MkdirAll(filepath.Dir(dbFile), 0700)
+}
+
+// Get configuration file path
+func configPath(name string) string {
+	return filepath.Join(configDir, name)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test resolvePaths precedence: flags > environment variables > XDG defaults
+func TestResolvePaths(t *testing.T) {
+	dbFileOri := dbFile
+	configDirOri := configDir
+	defer func() {
+		dbFile = dbFileOri
+		configDir = configDirOri
+	}()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	if got := filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), appName); got != filepath.Join(dir, "config", "domainSearcher") {
+		t.Errorf("Expected XDG_CONFIG_HOME based configuration directory, but got: %s", got)
+	}
+
+	// Relative XDG variables are invalid and ignored
+	t.Setenv("XDG_DATA_HOME", "relative")
+	home, _ := os.UserHomeDir()
+	if got := xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")); got != filepath.Join(home, ".local", "share") {
+		t.Errorf("Expected home based data directory, but got: %s", got)
+	}
+
+	t.Setenv("DOMAINSEARCHER_DB", filepath.Join(dir, "env", "env.db"))
+	t.Setenv("DOMAINSEARCHER_CONFIG", filepath.Join(dir, "envConfig"))
+	if err := resolvePaths("", ""); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if dbFile != filepath.Join(dir, "env", "env.db") || configDir != filepath.Join(dir, "envConfig") {
+		t.Errorf("Expected environment variables paths, but got: %s %s", dbFile, configDir)
+	}
+	if !checkFileExists(filepath.Join(dir, "env")) {
+		t.Errorf("Expected DB directory to be created")
+	}
+
+	// Relative flags are converted to absolute paths
+	if err := resolvePaths(filepath.Join(dir, "flag.db"), "flagConfig"); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	wd, _ := os.Getwd()
+	if dbFile != filepath.Join(dir, "flag.db") || configDir != filepath.Join(wd, "flagConfig") {
+		t.Errorf("Expected flags paths, but got: %s %s", dbFile, configDir)
+	}
+	if configPath("ovh.list") != filepath.Join(wd, "flagConfig", "ovh.list") {
+		t.Errorf("Expected ovh.list in flag configuration directory, but got: %s", configPath("ovh.list"))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+// Porkbun registered domains, API keys are sent in each request body and domains are listed in chunks
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Credentials file in configuration directory, one per line: porkbunId:apiKey:secretApiKey
+var porkbunFile = "porkbun.list"
+
+var porkbunEndpoint = "https://api.porkbun.com"
+
+// listAll returns up to 1000 domains starting at given offset
+const porkbunChunkSize = 1000
+
+type porkbunDomain struct {
+	Domain     string `json:"domain"`
+	ExpireDate string `json:"expireDate"`
+}
+
+// Get all domains requesting chunks until a partial one is returned
+var getPorkbunDomains = func(client *http.Client, apiKey, secretApiKey string) ([]porkbunDomain, error) {
+	domains := []porkbunDomain{}
+	for {
+		body, _ := json.Marshal(map[string]string{"apikey": apiKey, "secretapikey": secretApiKey, "start": strconv.Itoa(len(domains))})
+		r, err := http.NewRequest(http.MethodPost, porkbunEndpoint+"/api/json/v3/domain/listAll", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Content-Type", "application/json")
+
+		var response struct {
+			Status  string          `json:"status"`
+			Message string          `json:"message"`
+			Domains []porkbunDomain `json:"domains"`
+		}
+		// Errors are returned as JSON with a status message
+		body, err = fetchProviderResponse(client, r)
+		if jsonErr := json.Unmarshal(body, &response); jsonErr == nil && response.Status == "ERROR" {
+			return nil, fmt.Errorf("API error: %s", response.Message)
+		} else if err != nil {
+			return nil, err
+		} else if jsonErr != nil {
+			return nil, jsonErr
+		}
+		domains = append(domains, response.Domains...)
+		if len(response.Domains) < porkbunChunkSize {
+			return domains, nil
+		}
+	}
+}
+
+var populatePorkbun = func(db *sql.DB) error {
+	log := logger.With("provider", "porkbun")
+	accounts, err := readAccountsFile(configPath(porkbunFile), 3, 3)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "syntax", "porkbunId:apiKey:secretApiKey", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting Porkbun data:")
+	for _, dataFields := range accounts {
+		porkbunId, apiKey, secretApiKey := dataFields[0], dataFields[1], dataFields[2]
+		render.Info("-- porkbunId: %s", porkbunId)
+		log := providerLogger("porkbun", porkbunId)
+
+		client, err := newProviderClient("porkbun", porkbunId)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("porkbun", porkbunId, 0, err)
+			continue
+		}
+		domains, err := getPorkbunDomains(client, apiKey, secretApiKey)
+		if err != nil {
+			log.Error("Unable to list domains", "operation", "list_domains", "error", err)
+			recordAccountSync("porkbun", porkbunId, 0, err)
+			continue
+		}
+
+		records := []domainRecord{}
+		for _, domain := range domains {
+			name := strings.ToLower(strings.TrimSuffix(domain.Domain, "."))
+			if err := checkDNS(name); err != nil {
+				log.Warn("Skipping invalid domain name", "operation", "list_domains", "domain", domain.Domain, "error", err)
+				continue
+			}
+			// Expiration dates are "2006-01-02 15:04:05"
+			expires, _, _ := strings.Cut(domain.ExpireDate, " ")
+			records = append(records, domainRecord{ID: porkbunId, RealID: porkbunId, ISP: "porkbun", Domain: name, Expires: expires})
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert domains", "operation", "insert", "error", err)
+			recordAccountSync("porkbun", porkbunId, 0, err)
+			continue
+		}
+		recordAccountSync("porkbun", porkbunId, len(records), nil)
+	}
+	return nil
+}
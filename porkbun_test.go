@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test API keys in request body and chunks against a local Porkbun stand-in
+func TestPopulatePorkbun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.URL.Path != "/api/json/v3/domain/listAll" {
+			http.NotFound(w, r)
+			return
+		}
+		if request["apikey"] != "pk1_key" || request["secretapikey"] != "sk1_secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"ERROR","message":"Invalid API key. (002)"}`)
+			return
+		}
+		domains := []string{}
+		if request["start"] == "0" {
+			for i := 0; i < porkbunChunkSize; i++ {
+				domains = append(domains, fmt.Sprintf(`{"domain":"domain%d.dev","status":"ACTIVE","expireDate":"2030-03-01 23:59:59"}`, i))
+			}
+		} else if request["start"] == fmt.Sprint(porkbunChunkSize) {
+			// Invalid names are skipped
+			domains = append(domains, `{"domain":"last.dev","status":"ACTIVE","expireDate":"2031-03-01 23:59:59"}`, `{"domain":"bad_name.dev","status":"ACTIVE","expireDate":""}`)
+		}
+		fmt.Fprint(w, `{"status":"SUCCESS","domains":[`+strings.Join(domains, ",")+`]}`)
+	}))
+	defer server.Close()
+
+	porkbunEndpointOri := porkbunEndpoint
+	defer func() {
+		porkbunEndpoint = porkbunEndpointOri
+	}()
+	porkbunEndpoint = server.URL
+
+	withTestConfig(t, porkbunFile, "#porkbunId:apiKey:secretApiKey\nmain:pk1_key:sk1_secret\nrevoked:pk1_old:sk1_old\n")
+	syncResults = nil
+	records, err := populateTestDb(t, populatePorkbun)
+	if err != nil || len(records) != porkbunChunkSize+1 {
+		t.Fatalf("Expected %d domains, but got: %d %v", porkbunChunkSize+1, len(records), err)
+	}
+	if records[0] != (domainRecord{ID: "main", RealID: "main", ISP: "porkbun", Domain: "domain0.dev", Expires: "2030-03-01"}) {
+		t.Errorf("Unexpected first domain: %+v", records[0])
+	}
+	if failed := failedAccounts(); len(failed) != 1 || failed[0].Err.Error() != "API error: Invalid API key. (002)" {
+		t.Errorf("Expected revoked account failed, but got: %+v", failed)
+	}
+}
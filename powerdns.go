@@ -0,0 +1,237 @@
+package main
+
+// PowerDNS authoritative servers zones through their HTTP API, zones records can be cached too
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Servers file in configuration directory, one per line: serverName:apiKey:serverId:cacheRecords:apiURL
+// serverId defaults to localhost, cacheRecords true stores zones records in dns_records table
+var powerDNSFile = "powerdns.list"
+
+// Cached zone record, kept along with domain_list rows of the same domain, isp and id
+type dnsRecord struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	TTL     int    `json:"ttl"`
+	Content string `json:"content"`
+}
+
+const createRecordsTableSQL = `CREATE TABLE IF NOT EXISTS %sdns_records ( "domain" VARCHAR(100), "isp" VARCHAR(100), "id" VARCHAR(100), "name" VARCHAR(255), "type" VARCHAR(10), "ttl" INTEGER, "content" TEXT);`
+
+const recordsColumns = "domain, isp, id, name, type, ttl, content"
+
+type powerDNSRRSet struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	TTL     int    `json:"ttl"`
+	Records []struct {
+		Content  string `json:"content"`
+		Disabled bool   `json:"disabled"`
+	} `json:"records"`
+}
+
+func powerDNSRequest(apiUrl, apiKey string) (*http.Request, error) {
+	r, err := http.NewRequest(http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("X-API-Key", apiKey)
+	return r, nil
+}
+
+// Get server zones IDs by zone name
+var getPowerDNSZones = func(client *http.Client, apiUrl, apiKey, serverId string) (map[string]string, error) {
+	r, err := powerDNSRequest(strings.TrimSuffix(apiUrl, "/")+"/api/v1/servers/"+url.PathEscape(serverId)+"/zones", apiKey)
+	if err != nil {
+		return nil, err
+	}
+	var response []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := getProviderJSON(client, r, &response); err != nil {
+		return nil, err
+	}
+	zones := map[string]string{}
+	for _, zone := range response {
+		zones[zone.Name] = zone.ID
+	}
+	return zones, nil
+}
+
+// Get zone enabled records
+var getPowerDNSRecords = func(client *http.Client, apiUrl, apiKey, serverId, zoneId string) ([]dnsRecord, error) {
+	r, err := powerDNSRequest(strings.TrimSuffix(apiUrl, "/")+"/api/v1/servers/"+url.PathEscape(serverId)+"/zones/"+url.PathEscape(zoneId), apiKey)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		RRSets []powerDNSRRSet `json:"rrsets"`
+	}
+	if err := getProviderJSON(client, r, &response); err != nil {
+		return nil, err
+	}
+	records := []dnsRecord{}
+	for _, rrset := range response.RRSets {
+		for _, record := range rrset.Records {
+			if !record.Disabled {
+				records = append(records, dnsRecord{Name: strings.ToLower(strings.TrimSuffix(rrset.Name, ".")), Type: rrset.Type, TTL: rrset.TTL, Content: record.Content})
+			}
+		}
+	}
+	return records, nil
+}
+
+// Insert cached records of account domains in a single transaction
+func insertCachedRecords(db *sql.DB, isp, id string, records map[string][]dnsRecord) error {
+	if _, err := db.Exec(fmt.Sprintf(createRecordsTableSQL, "")); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for domain, domainRecords := range records {
+		for _, record := range domainRecords {
+			if _, err := tx.Exec("INSERT INTO dns_records ("+recordsColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)", domain, isp, id, record.Name, record.Type, record.TTL, record.Content); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Replace cached records along with domain_list in replaceDomainList transaction, failed accounts keep their previous ones
+func replaceCachedRecords(tx *sql.Tx, failed []accountSync) error {
+	for _, schema := range []string{"main.", "regenerated."} {
+		if _, err := tx.Exec(fmt.Sprintf(createRecordsTableSQL, schema)); err != nil {
+			return err
+		}
+	}
+	for _, account := range failed {
+		// Imported sources have no cached records
+		if account.Provider == manualProvider {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM regenerated.dns_records WHERE "+accountRowsWhere, accountRowsArgs(account)...); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO regenerated.dns_records ("+recordsColumns+") SELECT "+recordsColumns+" FROM main.dns_records WHERE "+accountRowsWhere, accountRowsArgs(account)...); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM main.dns_records"); err != nil {
+		return err
+	}
+	_, err := tx.Exec("INSERT INTO main.dns_records (" + recordsColumns + ") SELECT " + recordsColumns + " FROM regenerated.dns_records")
+	return err
+}
+
+// Get cached records of domain_list row, none when caching was never enabled
+func cachedRecords(db *sql.DB, record domainRecord) ([]dnsRecord, error) {
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='dns_records'").Scan(&tables); err != nil || tables == 0 {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT name, type, ttl, content FROM dns_records WHERE domain = ? AND isp = ? AND id = ? ORDER BY name, type, content", record.Domain, record.ISP, record.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []dnsRecord{}
+	for rows.Next() {
+		var cached dnsRecord
+		if err := rows.Scan(&cached.Name, &cached.Type, &cached.TTL, &cached.Content); err != nil {
+			return nil, err
+		}
+		records = append(records, cached)
+	}
+	return records, rows.Err()
+}
+
+var populatePowerDNS = func(db *sql.DB) error {
+	log := logger.With("provider", "powerdns")
+	accounts, err := readAccountsFile(configPath(powerDNSFile), 5, 5)
+	if err != nil {
+		log.Error("Unable to read servers file", "operation", "read_config", "syntax", "serverName:apiKey:serverId:cacheRecords:apiURL", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting PowerDNS data:")
+	for _, dataFields := range accounts {
+		serverName, apiKey, serverId, apiUrl := dataFields[0], dataFields[1], dataFields[2], dataFields[4]
+		if serverId == "" {
+			serverId = "localhost"
+		}
+		cacheRecords := false
+		if dataFields[3] != "" {
+			if cacheRecords, err = strconv.ParseBool(dataFields[3]); err != nil {
+				log.Error("Invalid cacheRecords value, use true or false", "operation", "read_config", "server", serverName, "error", err)
+				recordAccountSync("powerdns", serverName, 0, err)
+				continue
+			}
+		}
+		render.Info("-- serverName: %s", serverName)
+		log := providerLogger("powerdns", serverName)
+
+		client, err := newProviderClient("powerdns", serverName)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("powerdns", serverName, 0, err)
+			continue
+		}
+		zones, err := getPowerDNSZones(client, apiUrl, apiKey, serverId)
+		if err != nil {
+			log.Error("Unable to list zones", "operation", "list_zones", "error", err)
+			recordAccountSync("powerdns", serverName, 0, err)
+			continue
+		}
+
+		// Server name is used as account ID and realId
+		records := []domainRecord{}
+		zoneRecords := map[string][]dnsRecord{}
+		for name, zoneId := range zones {
+			domain := strings.ToLower(strings.TrimSuffix(name, "."))
+			if err := checkDNS(domain); err != nil {
+				log.Warn("Skipping invalid zone name", "operation", "list_zones", "zone", name, "error", err)
+				continue
+			}
+			records = append(records, domainRecord{ID: serverName, RealID: serverName, ISP: "powerdns", Domain: domain})
+			if cacheRecords {
+				if zoneRecords[domain], err = getPowerDNSRecords(client, apiUrl, apiKey, serverId, zoneId); err != nil {
+					log.Error("Unable to get zone records", "operation", "list_records", "zone", name, "error", err)
+					break
+				}
+			}
+		}
+		if err != nil {
+			recordAccountSync("powerdns", serverName, 0, err)
+			continue
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert zones", "operation", "insert", "error", err)
+			recordAccountSync("powerdns", serverName, 0, err)
+			continue
+		}
+		if err := insertCachedRecords(db, "powerdns", serverName, zoneRecords); err != nil {
+			log.Error("Unable to insert zones records", "operation", "insert", "error", err)
+			recordAccountSync("powerdns", serverName, 0, err)
+			continue
+		}
+		recordAccountSync("powerdns", serverName, len(records), nil)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test zones and cached records against a local PowerDNS stand-in, failed servers keep their previous records
+func TestPopulatePowerDNS(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" || failing {
+			http.Error(w, `{"error":"Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/servers/localhost/zones":
+			fmt.Fprint(w, `[{"id":"internal.example.com.","name":"internal.example.com.","kind":"Native"},{"id":"example.org.","name":"example.org.","kind":"Master"}]`)
+		case "/api/v1/servers/localhost/zones/example.org.":
+			fmt.Fprint(w, `{"name":"example.org.","rrsets":[{"name":"www.example.org.","type":"A","ttl":300,"records":[{"content":"192.0.2.1","disabled":false},{"content":"192.0.2.2","disabled":true}]},{"name":"example.org.","type":"NS","ttl":3600,"records":[{"content":"ns1.example.com.","disabled":false}]}]}`)
+		case "/api/v1/servers/localhost/zones/internal.example.com.":
+			fmt.Fprint(w, `{"name":"internal.example.com.","rrsets":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	withTestDb(t)
+	mockProvidersSync(t, false)
+	withTestConfig(t, powerDNSFile, "#serverName:apiKey:serverId:cacheRecords:apiURL\nns-internal:secret::true:"+server.URL+"/\n")
+	records, err := populateTestDb(t, populatePowerDNS)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 zones, but got: %+v %v", records, err)
+	}
+	if records[0] != (domainRecord{ID: "ns-internal", RealID: "ns-internal", ISP: "powerdns", Domain: "example.org"}) {
+		t.Errorf("Unexpected example.org record: %+v", records[0])
+	}
+
+	regenerateDb(dbFile)
+	failing = true
+	regenerateDb(dbFile)
+	exitCode, out := captureRun(t, "search", "-details", "-max-age", "0", "example.org")
+	if exitCode != exitFound || !strings.Contains(out, "RECORDS:\n   example.org 3600 NS ns1.example.com.\n   www.example.org 300 A 192.0.2.1\n") || strings.Contains(out, "192.0.2.2") {
+		t.Errorf("Expected example.org cached records, but got: %d %s", exitCode, out)
+	}
+	if !strings.Contains(out, "WARNING: powerdns / ns-internal failed in last sync") {
+		t.Errorf("Expected failed server warning, but got: %s", out)
+	}
+
+	withTestConfig(t, powerDNSFile, "ns-internal:secret:localhost:maybe:"+server.URL+"\n")
+	syncResults = nil
+	if _, err := populateTestDb(t, populatePowerDNS); err != nil || len(failedAccounts()) != 1 {
+		t.Errorf("Expected invalid cacheRecords account failed, but got: %+v %v", failedAccounts(), err)
+	}
+}
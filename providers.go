@@ -0,0 +1,140 @@
+package main
+
+// Helpers shared by optional providers, they are skipped when their accounts file doesn't exist
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Provider API requests timeout
+var providerTimeout = 60 * time.Second
+
+// Get accounts file lines fields, nil when file doesn't exist. Lines are split in maxFields at most,
+// so last field can contain colons: URLs, paths or commands
+func readAccountsFile(file string, minFields, maxFields int) ([][]string, error) {
+	if !checkFileExists(file) {
+		return nil, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	accounts := [][]string{}
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		// Skip empty and comment lines
+		if line == "" || line[0:1] == "#" {
+			continue
+		}
+		dataFields := strings.SplitN(line, ":", maxFields)
+		if len(dataFields) < minFields || dataFields[0] == "" {
+			// Lines aren't shown, they contain credentials
+			return nil, fmt.Errorf("%s line %d: expected at least %d fields", file, lineNumber, minFields)
+		}
+		accounts = append(accounts, dataFields)
+	}
+	return accounts, scanner.Err()
+}
+
+// Optional field, empty when missing
+func accountField(dataFields []string, i int) string {
+	if i < len(dataFields) {
+		return dataFields[i]
+	}
+	return ""
+}
+
+// Insert provider account domains in a single transaction, so failed accounts don't leave partial data
+func insertAccountDomains(db *sql.DB, records []domainRecord) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	statement, err := tx.Prepare("INSERT INTO domain_list (id, realId, isp, domain, endpoint, expires, source, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+	for _, record := range records {
+		if _, err := statement.Exec(record.ID, record.RealID, record.ISP, record.Domain, record.Endpoint, record.Expires, record.Source, record.Status); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Get HTTP client for provider account API access, with proxy configuration and timeout
+func newProviderClient(provider, account string) (*http.Client, error) {
+	client, err := newHTTPClient(provider, account)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = providerTimeout
+	return client, nil
+}
+
+// Get provider API response body, non 2xx responses are returned as errors along with their body
+func fetchProviderResponse(client *http.Client, r *http.Request) ([]byte, error) {
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := strings.TrimSpace(string(body))
+		if len(message) > 200 {
+			message = message[:200]
+		}
+		return body, fmt.Errorf("API error %s: %s", resp.Status, message)
+	}
+	return body, nil
+}
+
+// Get provider API JSON response
+func getProviderJSON(client *http.Client, r *http.Request, v any) error {
+	body, err := fetchProviderResponse(client, r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// Get OAuth2 access token posting given grant form to token endpoint
+func fetchOAuthToken(client *http.Client, tokenUrl string, form url.Values) (string, error) {
+	r, err := http.NewRequest(http.MethodPost, tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := getProviderJSON(client, r, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("No access token in %s response", tokenUrl)
+	}
+	return token.AccessToken, nil
+}
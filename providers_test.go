@@ -0,0 +1,47 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// Run provider populate function against an empty DB returning inserted domains
+func populateTestDb(t *testing.T, populate func(db *sql.DB) error) ([]domainRecord, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := createTable(db); err != nil {
+		t.Fatal(err)
+	}
+	populateErr := populate(db)
+
+	rows, err := db.Query("SELECT " + domainRecordColumns + " FROM domain_list ORDER BY isp, domain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	records, err := scanDomainRecords(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records, populateErr
+}
+
+// Test accounts files lines are split keeping colons in last field
+func TestReadAccountsFile(t *testing.T) {
+	withTestConfig(t, "test.list", "# comment\n\nname:key:https://example.com:8080/api\nother:key\n")
+	accounts, err := readAccountsFile(configPath("test.list"), 2, 3)
+	if err != nil || len(accounts) != 2 || accounts[0][2] != "https://example.com:8080/api" || accountField(accounts[1], 2) != "" {
+		t.Errorf("Expected 2 accounts, but got: %q %v", accounts, err)
+	}
+
+	if accounts, err := readAccountsFile(configPath("missing.list"), 2, 3); accounts != nil || err != nil {
+		t.Errorf("Expected nil accounts for missing file, but got: %q %v", accounts, err)
+	}
+	withTestConfig(t, "test.list", "name:key\nsecret\n")
+	if _, err := readAccountsFile(configPath("test.list"), 2, 3); err == nil || err.Error() != configPath("test.list")+" line 2: expected at least 2 fields" {
+		t.Errorf("Expected line 2 error, but got: %v", err)
+	}
+}
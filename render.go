@@ -0,0 +1,108 @@
+package main
+
+// Terminal output rendering, all colored output goes through render so
+// colors, screen clearing and banner can be disabled in a single place
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/inancgumus/screen"
+	"github.com/mattn/go-isatty"
+)
+
+// isTerminal wrapped in order to be able to mock it
+var isTerminal = func(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+type renderer struct {
+	// Stdout is a terminal, banner and screen clearing are only shown in terminals
+	tty bool
+	// Only results, warnings and errors are shown
+	quiet bool
+
+	info   *color.Color
+	result *color.Color
+	warn   *color.Color
+	err    *color.Color
+}
+
+var render = newRenderer(false, false)
+
+// Colors are disabled when stdout is not a terminal, NO_COLOR is set, TERM is dumb or noColor is requested
+func newRenderer(noColor, quiet bool) *renderer {
+	r := &renderer{
+		tty:    isTerminal(os.Stdout),
+		quiet:  quiet,
+		info:   color.New(color.FgCyan),
+		result: color.New(color.FgGreen),
+		warn:   color.New(color.FgYellow),
+		err:    color.New(color.FgRed),
+	}
+
+	color.NoColor = noColor || !r.tty || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
+	return r
+}
+
+// color.Output/color.Error are evaluated in each call, tests redirect them
+func (r *renderer) print(w io.Writer, c *color.Color, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	c.Fprint(w, message)
+}
+
+// Progress messages, hidden in quiet mode
+func (r *renderer) Info(format string, a ...interface{}) {
+	if r.quiet {
+		return
+	}
+	r.print(color.Output, r.info, format, a...)
+}
+
+// Results decoration, separators and headers
+func (r *renderer) Text(format string, a ...interface{}) {
+	r.print(color.Output, r.info, format, a...)
+}
+
+// Results
+func (r *renderer) Result(format string, a ...interface{}) {
+	r.print(color.Output, r.result, format, a...)
+}
+
+// Not found domains, invalid input and other warnings
+func (r *renderer) Warn(format string, a ...interface{}) {
+	r.print(color.Output, r.warn, format, a...)
+}
+
+// Errors are written to stderr so they don't get mixed with piped results
+func (r *renderer) Error(format string, a ...interface{}) {
+	r.print(color.Error, r.err, format, a...)
+}
+
+// Interactive prompt
+func (r *renderer) Prompt(prompt string) string {
+	return r.info.Sprint(prompt)
+}
+
+// Clear screen and show banner, only in terminals
+func (r *renderer) Banner() {
+	if !r.tty || r.quiet {
+		return
+	}
+
+	//fmt.Print("\033[H\033[2J")
+	// Portable clear screen version
+	screen.MoveTopLeft()
+	screen.Clear()
+	r.Info("######################################################################################")
+	r.Info("| OVH-Cloudflare-GoDaddy-DonDominio(SOCKS-5) NS/Whois search system: Ctrl+c -> Exit  |")
+	r.Info("| v0.9-sqlite-cli - coded by Kr0m: alfaexploit.com                                   |")
+	r.Info("######################################################################################")
+	r.Info("")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// Run function capturing renderer stdout and stderr output
+func captureRender(f func()) (string, string) {
+	colorOutputOri := color.Output
+	colorErrorOri := color.Error
+	defer func() {
+		color.Output = colorOutputOri
+		color.Error = colorErrorOri
+	}()
+
+	var stdout, stderr bytes.Buffer
+	color.Output = &stdout
+	color.Error = &stderr
+	f()
+	return stdout.String(), stderr.String()
+}
+
+// Test renderer colors detection
+func TestNewRenderer(t *testing.T) {
+	isTerminalOri := isTerminal
+	noColorOri := color.NoColor
+	defer func() {
+		isTerminal = isTerminalOri
+		color.NoColor = noColorOri
+	}()
+	isTerminal = func(f *os.File) bool {
+		return true
+	}
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+
+	r := newRenderer(false, false)
+	stdout, _ := captureRender(func() { r.Result("  DOMAIN: %s", "example.com") })
+	if stdout != "\x1b[32m  DOMAIN: example.com\n\x1b[0m" {
+		t.Errorf("Expected green output in terminal, but got: %q", stdout)
+	}
+
+	r = newRenderer(true, false)
+	stdout, _ = captureRender(func() { r.Result("  DOMAIN: %s", "example.com") })
+	if stdout != "  DOMAIN: example.com\n" {
+		t.Errorf("Expected no colors with -no-color, but got: %q", stdout)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	r = newRenderer(false, false)
+	stdout, _ = captureRender(func() { r.Warn("  NOT FOUND") })
+	if stdout != "  NOT FOUND\n" {
+		t.Errorf("Expected no colors with NO_COLOR, but got: %q", stdout)
+	}
+
+	t.Setenv("NO_COLOR", "")
+	isTerminal = func(f *os.File) bool {
+		return false
+	}
+	r = newRenderer(false, false)
+	stdout, _ = captureRender(func() { r.Warn("  NOT FOUND") })
+	if stdout != "  NOT FOUND\n" {
+		t.Errorf("Expected no colors without terminal, but got: %q", stdout)
+	}
+	stdout, _ = captureRender(r.Banner)
+	if stdout != "" {
+		t.Errorf("Expected no banner without terminal, but got: %q", stdout)
+	}
+}
+
+// Test renderer quiet mode and errors output
+func TestRendererQuiet(t *testing.T) {
+	noColorOri := color.NoColor
+	defer func() {
+		color.NoColor = noColorOri
+	}()
+
+	r := newRenderer(true, true)
+	stdout, stderr := captureRender(func() {
+		r.Info("> Populating DB")
+		r.Result("ovh / realId")
+		r.Warn("  Invalid domain")
+		r.Error("++ ERROR: %s", "failure")
+	})
+	if stdout != "ovh / realId\n  Invalid domain\n" {
+		t.Errorf("Expected only results and warnings in stdout, but got: %q", stdout)
+	}
+	if stderr != "++ ERROR: failure\n" {
+		t.Errorf("Expected errors in stderr, but got: %q", stderr)
+	}
+}
+
+// Test shell command doesn't clear screen nor show banner when output is not a terminal
+func TestShellNoTerminal(t *testing.T) {
+	withTestDb(t)
+
+	isTerminalOri := isTerminal
+	defer func() {
+		isTerminal = isTerminalOri
+	}()
+	isTerminal = func(f *os.File) bool {
+		return false
+	}
+
+	exitCode, out := captureRun(t, "shell", "-exit")
+	if exitCode != exitOK {
+		t.Errorf("Expected exit code %d, but got: %d", exitOK, exitCode)
+	}
+	if strings.Contains(out, "coded by Kr0m") || strings.Contains(out, "\x1b") {
+		t.Errorf("Expected no banner nor escape codes, but got: %q", out)
+	}
+	if !strings.Contains(out, "> DB: ") {
+		t.Errorf("Expected progress messages, but got: %q", out)
+	}
+
+	exitCode, out = captureRun(t, "shell", "-exit", "-quiet")
+	if exitCode != exitOK || out != "" {
+		t.Errorf("Expected no output in quiet mode, but got: %d %q", exitCode, out)
+	}
+}
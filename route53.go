@@ -0,0 +1,268 @@
+package main
+
+// AWS Route 53 hosted zones, requests are signed with Signature Version 4 and accounts can assume a role
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials file in configuration directory, one per line: route53Id:accessKeyId:secretAccessKey[:roleArn]
+var route53File = "route53.list"
+
+// Route 53 and STS API global endpoints, both are signed for us-east-1 region
+var (
+	route53Endpoint = "https://route53.amazonaws.com"
+	stsEndpoint     = "https://sts.amazonaws.com"
+)
+
+const awsRegion = "us-east-1"
+
+type awsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+}
+
+type route53Zone struct {
+	ID      string `xml:"Id"`
+	Name    string `xml:"Name"`
+	Private bool   `xml:"Config>PrivateZone"`
+}
+
+type route53ZonesResponse struct {
+	Zones       []route53Zone `xml:"HostedZones>HostedZone"`
+	IsTruncated bool          `xml:"IsTruncated"`
+	NextMarker  string        `xml:"NextMarker"`
+}
+
+type awsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Query string with sorted keys and RFC 3986 encoding
+func awsCanonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+	pairs := []string{}
+	for _, key := range keys {
+		params := append([]string(nil), values[key]...)
+		sort.Strings(params)
+		for _, value := range params {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Sign request with AWS Signature Version 4, host and X-Amz-* headers are signed
+func signAWSRequest(r *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	r.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": r.URL.Host}
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{r.Method, path, awsCanonicalQuery(r.URL.Query()), canonicalHeaders, signedHeaders, sha256Hex(body)}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// Signed GET request decoding XML response, AWS errors are returned with their code and message
+func awsGet(client *http.Client, creds awsCredentials, service, apiUrl string, v any) error {
+	r, err := http.NewRequest(http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return err
+	}
+	signAWSRequest(r, nil, creds, awsRegion, service, time.Now())
+
+	body, err := fetchProviderResponse(client, r)
+	if err != nil {
+		var awsErr awsErrorResponse
+		if xml.Unmarshal(body, &awsErr) == nil && awsErr.Code != "" {
+			return fmt.Errorf("%s API error %s: %s", service, awsErr.Code, awsErr.Message)
+		}
+		return err
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// Get temporary credentials for given role
+var assumeAWSRole = func(client *http.Client, creds awsCredentials, roleArn string) (awsCredentials, error) {
+	query := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {roleArn},
+		"RoleSessionName": {"domainSearcher"},
+		"DurationSeconds": {"900"},
+	}
+	var response struct {
+		Credentials awsCredentials `xml:"AssumeRoleResult>Credentials"`
+	}
+	if err := awsGet(client, creds, "sts", stsEndpoint+"/?"+query.Encode(), &response); err != nil {
+		return awsCredentials{}, err
+	}
+	if response.Credentials.AccessKeyID == "" {
+		return awsCredentials{}, fmt.Errorf("No credentials in AssumeRole response")
+	}
+	return response.Credentials, nil
+}
+
+// Get all hosted zones following pagination markers
+var getRoute53Zones = func(client *http.Client, creds awsCredentials) ([]route53Zone, error) {
+	zones := []route53Zone{}
+	marker := ""
+	for {
+		query := url.Values{"maxitems": {"100"}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		var response route53ZonesResponse
+		if err := awsGet(client, creds, "route53", route53Endpoint+"/2013-04-01/hostedzone?"+query.Encode(), &response); err != nil {
+			return nil, err
+		}
+		zones = append(zones, response.Zones...)
+		if !response.IsTruncated || response.NextMarker == "" {
+			return zones, nil
+		}
+		marker = response.NextMarker
+	}
+}
+
+// Account ID from role ARN: arn:aws:iam::123456789012:role/name
+func awsRoleAccount(roleArn string) string {
+	fields := strings.Split(roleArn, ":")
+	if len(fields) > 4 {
+		return fields[4]
+	}
+	return ""
+}
+
+var populateRoute53 = func(db *sql.DB) error {
+	log := logger.With("provider", "route53")
+	accounts, err := readAccountsFile(configPath(route53File), 3, 4)
+	if err != nil {
+		log.Error("Unable to read credentials file", "operation", "read_config", "syntax", "route53Id:accessKeyId:secretAccessKey[:roleArn]", "error", err)
+		return err
+	}
+	if accounts == nil {
+		return nil
+	}
+
+	render.Info("")
+	render.Info("- Getting Route 53 data:")
+	for _, dataFields := range accounts {
+		route53Id := dataFields[0]
+		creds := awsCredentials{AccessKeyID: dataFields[1], SecretAccessKey: dataFields[2]}
+		roleArn := accountField(dataFields, 3)
+		render.Info("-- route53Id: %s", route53Id)
+		log := providerLogger("route53", route53Id)
+
+		client, err := newProviderClient("route53", route53Id)
+		if err != nil {
+			log.Error("Unable to configure proxy", "operation", "create_client", "error", err)
+			recordAccountSync("route53", route53Id, 0, err)
+			continue
+		}
+
+		// Role account ID is used as realId
+		realId := route53Id
+		if roleArn != "" {
+			if creds, err = assumeAWSRole(client, creds, roleArn); err != nil {
+				log.Error("Unable to assume role", "operation", "assume_role", "role", roleArn, "error", err)
+				recordAccountSync("route53", route53Id, 0, err)
+				continue
+			}
+			if account := awsRoleAccount(roleArn); account != "" {
+				realId = account
+			}
+		}
+
+		zones, err := getRoute53Zones(client, creds)
+		if err != nil {
+			log.Error("Unable to list hosted zones", "operation", "list_zones", "error", err)
+			recordAccountSync("route53", route53Id, 0, err)
+			continue
+		}
+
+		// Private zones are flagged by route53-private ISP
+		records := []domainRecord{}
+		for _, zone := range zones {
+			domain := strings.ToLower(strings.TrimSuffix(zone.Name, "."))
+			if err := checkDNS(domain); err != nil {
+				log.Warn("Skipping invalid zone name", "operation", "list_zones", "zone", zone.Name, "error", err)
+				continue
+			}
+			isp := "route53"
+			if zone.Private {
+				isp = "route53-private"
+			}
+			records = append(records, domainRecord{ID: route53Id, RealID: realId, ISP: isp, Domain: domain})
+		}
+		if err := insertAccountDomains(db, records); err != nil {
+			log.Error("Unable to insert hosted zones", "operation", "insert", "error", err)
+			recordAccountSync("route53", route53Id, 0, err)
+			continue
+		}
+		recordAccountSync("route53", route53Id, len(records), nil)
+	}
+	return nil
+}
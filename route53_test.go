@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test signature against AWS Signature Version 4 test suite get-vanilla-query-order-key-case request
+func TestSignAWSRequest(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWSRequest(r, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"
+	if r.Header.Get("Authorization") != expected {
+		t.Errorf("Expected %s, but got: %s", expected, r.Header.Get("Authorization"))
+	}
+}
+
+// Test hosted zones pagination, private zones and role credentials against a local Route 53 stand-in
+func TestPopulateRoute53(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if r.URL.Path == "/" && r.URL.Query().Get("Action") == "AssumeRole" {
+			if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDBASE/") || !strings.Contains(authorization, "/us-east-1/sts/aws4_request") {
+				http.Error(w, "<ErrorResponse><Error><Code>InvalidClientTokenId</Code><Message>Invalid token</Message></Error></ErrorResponse>", http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>roleSecret</SecretAccessKey><SessionToken>roleToken</SessionToken></Credentials></AssumeRoleResult></AssumeRoleResponse>`)
+			return
+		}
+		if r.URL.Path != "/2013-04-01/hostedzone" || !strings.Contains(authorization, "/us-east-1/route53/aws4_request") {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.Contains(authorization, "Credential=AKIDDENIED/") {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>Not authorized</Message></Error></ErrorResponse>`)
+			return
+		}
+		if strings.Contains(authorization, "Credential=ASIAROLE/") {
+			if r.Header.Get("X-Amz-Security-Token") != "roleToken" {
+				t.Errorf("Expected session token in role requests")
+			}
+			fmt.Fprint(w, `<ListHostedZonesResponse><HostedZones><HostedZone><Id>/hostedzone/Z3</Id><Name>role.com.</Name><Config><PrivateZone>false</PrivateZone></Config></HostedZone></HostedZones><IsTruncated>false</IsTruncated></ListHostedZonesResponse>`)
+			return
+		}
+		if r.URL.Query().Get("marker") == "" {
+			fmt.Fprint(w, `<ListHostedZonesResponse><HostedZones><HostedZone><Id>/hostedzone/Z1</Id><Name>Example.com.</Name><Config><PrivateZone>false</PrivateZone></Config></HostedZone><HostedZone><Id>/hostedzone/Z2</Id><Name>\052.example.com.</Name></HostedZone></HostedZones><IsTruncated>true</IsTruncated><NextMarker>Z4</NextMarker></ListHostedZonesResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<ListHostedZonesResponse><HostedZones><HostedZone><Id>/hostedzone/Z4</Id><Name>internal.example.com.</Name><Config><PrivateZone>true</PrivateZone></Config></HostedZone></HostedZones><IsTruncated>false</IsTruncated></ListHostedZonesResponse>`)
+	}))
+	defer server.Close()
+
+	route53EndpointOri := route53Endpoint
+	stsEndpointOri := stsEndpoint
+	defer func() {
+		route53Endpoint = route53EndpointOri
+		stsEndpoint = stsEndpointOri
+	}()
+	route53Endpoint = server.URL
+	stsEndpoint = server.URL
+
+	withTestConfig(t, route53File, "#route53Id:accessKeyId:secretAccessKey[:roleArn]\nmain:AKIDMAIN:secret\nprod:AKIDBASE:secret:arn:aws:iam::123456789012:role/domainSearcher\ndenied:AKIDDENIED:secret\n")
+	syncResults = nil
+	records, err := populateTestDb(t, populateRoute53)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Expected 3 hosted zones, but got: %+v %v", records, err)
+	}
+	for i, expected := range []domainRecord{
+		{ID: "main", RealID: "main", ISP: "route53", Domain: "example.com"},
+		{ID: "prod", RealID: "123456789012", ISP: "route53", Domain: "role.com"},
+		{ID: "main", RealID: "main", ISP: "route53-private", Domain: "internal.example.com"},
+	} {
+		if records[i] != expected {
+			t.Errorf("Expected %+v, but got: %+v", expected, records[i])
+		}
+	}
+
+	// Denied account fails alone
+	failed := failedAccounts()
+	if len(failed) != 1 || failed[0].Account != "denied" || !strings.Contains(failed[0].Err.Error(), "AccessDenied: Not authorized") {
+		t.Errorf("Expected AccessDenied error for denied account, but got: %+v", failed)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+// Cron alike schedules: minute hour day-of-month month day-of-week
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule macros
+var scheduleMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+type schedule struct {
+	// Allowed values bitmasks
+	minute, hour, dom, month, dow uint64
+	// Cron matches day-of-month or day-of-week when both are restricted
+	domAny, dowAny bool
+}
+
+// Parse field: *, */n, a, a-b, a-b/n and comma separated lists of them
+func parseScheduleField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, found := strings.Cut(part, "/"); found {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("Invalid step %s", part)
+			}
+			part = rangePart
+		}
+
+		first, last := min, max
+		if part != "*" {
+			firstPart, lastPart, isRange := strings.Cut(part, "-")
+			var err error
+			if first, err = strconv.Atoi(firstPart); err != nil {
+				return 0, fmt.Errorf("Invalid value %s", part)
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(lastPart); err != nil {
+					return 0, fmt.Errorf("Invalid value %s", part)
+				}
+			} else if step > 1 {
+				// a/n is a-max/n
+				last = max
+			}
+		}
+		if first < min || last > max || first > last {
+			return 0, fmt.Errorf("Value %s out of range %d-%d", part, min, max)
+		}
+		for value := first; value <= last; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func parseSchedule(expression string) (*schedule, error) {
+	if macro, ok := scheduleMacros[expression]; ok {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Invalid schedule %q, syntax: minute hour day-of-month month day-of-week", expression)
+	}
+
+	s := &schedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	for i, field := range []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59},
+		{&s.hour, 0, 23},
+		{&s.dom, 1, 31},
+		{&s.month, 1, 12},
+		// 0 and 7 are Sunday
+		{&s.dow, 0, 7},
+	} {
+		bits, err := parseScheduleField(fields[i], field.min, field.max)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid schedule %q: %v", expression, err)
+		}
+		*field.bits = bits
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func (s *schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// Get first matching time after t, zero time when nothing matches in 5 years: 31 of February for example
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Run job on schedule until ctx is cancelled, a job running when its next time arrives delays it
+func runSchedule(ctx context.Context, s *schedule, job func()) {
+	for {
+		next := s.next(time.Now())
+		if next.IsZero() {
+			logger.Error("Schedule never matches, stopping it", "operation", "schedule")
+			return
+		}
+		logger.Info("Next scheduled sync", "operation", "schedule", "at", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			job()
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+// HTTP REST API server, lookups are answered from the same DB used by CLI
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Default listen address, only local access unless configured otherwise
+const defaultListen = "127.0.0.1:8080"
+
+// search endpoint results limits
+const (
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// Time given to in-flight requests on shutdown
+var shutdownTimeout = 10 * time.Second
+
+type apiServer struct {
+	dbFile string
+	// Token authentication, enabled by default
+	auth      bool
+	accessLog *slog.Logger
+	lookups   *lookupMetrics
+
+	// DB is opened once dbFile exists, syncs replace its domains in place so the handle is kept
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func newAPIServer(dbFile string) *apiServer {
+	return &apiServer{dbFile: dbFile, auth: true, accessLog: logger, lookups: newLookupMetrics()}
+}
+
+// Get DB handle, shared by all requests until server is closed
+func (s *apiServer) database() (*sql.DB, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil {
+		return s.db, nil
+	}
+	// Opening a missing file would create an empty DB, first sync creates it
+	if _, err := os.Stat(s.dbFile); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", s.dbFile)
+	if err != nil {
+		return nil, err
+	}
+	s.db = db
+	return db, nil
+}
+
+func (s *apiServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /domains", s.handleDomains)
+	mux.HandleFunc("GET /domains/{name}", s.handleDomain)
+	mux.HandleFunc("GET /search", s.handleSearch)
+	mux.HandleFunc("GET /accounts", s.handleAccounts)
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.Handle("GET /{$}", webHandler())
+	mux.Handle("GET "+webPrefix, webHandler())
+	return s.authenticate(mux)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+type domainResponse struct {
+	Domain  string         `json:"domain"`
+	Found   bool           `json:"found"`
+	Holders []domainRecord `json:"holders"`
+	// details=true only
+	NS            []string `json:"ns,omitempty"`
+	Whois         string   `json:"whois,omitempty"`
+	DetailsErrors []string `json:"detailsErrors,omitempty"`
+}
+
+// GET /domains/{name}[?details=true]: 200 found, 404 not found, 400 invalid domain.
+// details queries NS servers and WHOIS info
+func (s *apiServer) handleDomain(w http.ResponseWriter, r *http.Request) {
+	start, result := time.Now(), lookupError
+	defer func() {
+		s.lookups.observe("domain", result, time.Since(start))
+	}()
+
+	name := strings.ToLower(strings.TrimSuffix(r.PathValue("name"), "."))
+	if len(name) >= 100 || checkDNS(name) != nil {
+		result = lookupInvalid
+		writeJSONError(w, http.StatusBadRequest, errInvalidDomain.Error())
+		return
+	}
+
+	db, err := s.database()
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "api_domain", "db", s.dbFile, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	records, err := lookupDomain(db, name)
+	if err != nil {
+		logger.Error("Unable to query domain", "operation", "api_domain", "domain", name, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	// Domains held by other accounts are not found for restricted tokens
+	if token := contextToken(r.Context()); token != nil {
+		allowed := []domainRecord{}
+		for _, record := range records {
+			if token.allows(record) {
+				allowed = append(allowed, record)
+			}
+		}
+		records = allowed
+	}
+
+	// Transferred out holders are shown, but they don't hold the domain
+	response := domainResponse{Domain: name, Holders: records}
+	for _, record := range records {
+		response.Found = response.Found || record.held()
+	}
+	if r.URL.Query().Get("details") == "true" {
+		if ns, err := getDnsNs(name); err != nil {
+			response.DetailsErrors = append(response.DetailsErrors, "NS: "+err.Error())
+		} else {
+			for _, v := range ns {
+				response.NS = append(response.NS, v.Host)
+			}
+		}
+		if whoisResponse, err := getWhois(name); err != nil {
+			response.DetailsErrors = append(response.DetailsErrors, "WHOIS: "+err.Error())
+		} else {
+			response.Whois = whoisResponse.WHOISRaw
+		}
+	}
+
+	status, result := http.StatusOK, lookupFound
+	if !response.Found {
+		status, result = http.StatusNotFound, lookupNotFound
+	}
+	writeJSON(w, status, response)
+}
+
+// All domains visible by token, nil token sees all of them
+func listDomains(db *sql.DB, token *apiToken) ([]domainRecord, error) {
+	where, args := token.where()
+	rows, err := db.Query("SELECT "+domainRecordColumns+" FROM domain_list WHERE "+where+" ORDER BY domain, isp, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDomainRecords(rows)
+}
+
+// GET /domains
+func (s *apiServer) handleDomains(w http.ResponseWriter, r *http.Request) {
+	db, err := s.database()
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "api_domains", "db", s.dbFile, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	records, err := listDomains(db, contextToken(r.Context()))
+	if err != nil {
+		logger.Error("Unable to list domains", "operation", "api_domains", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// Domains containing query string visible by token, nil token sees all of them
+func searchDomains(db *sql.DB, query string, limit int, token *apiToken) ([]domainRecord, error) {
+	// LIKE wildcards in query are literal characters
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	where, args := token.where()
+	args = append([]interface{}{pattern}, append(args, limit)...)
+	rows, err := db.Query(`SELECT `+domainRecordColumns+` FROM domain_list WHERE domain LIKE ? ESCAPE '\' AND `+where+` ORDER BY domain, isp, id LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanDomainRecords(rows)
+}
+
+type searchResponse struct {
+	Query   string         `json:"query"`
+	Results []domainRecord `json:"results"`
+}
+
+// GET /search?q=QUERY[&limit=N]
+func (s *apiServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	start, result := time.Now(), lookupError
+	defer func() {
+		s.lookups.observe("search", result, time.Since(start))
+	}()
+
+	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	if query == "" {
+		result = lookupInvalid
+		writeJSONError(w, http.StatusBadRequest, "Missing q parameter")
+		return
+	}
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxSearchLimit {
+			result = lookupInvalid
+			writeJSONError(w, http.StatusBadRequest, "Invalid limit parameter, allowed values: 1-"+strconv.Itoa(maxSearchLimit))
+			return
+		}
+	}
+
+	db, err := s.database()
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "api_search", "db", s.dbFile, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	records, err := searchDomains(db, query, limit, contextToken(r.Context()))
+	if err != nil {
+		logger.Error("Unable to search domains", "operation", "api_search", "query", query, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	result = lookupFound
+	if len(records) == 0 {
+		result = lookupNotFound
+	}
+	writeJSON(w, http.StatusOK, searchResponse{Query: query, Results: records})
+}
+
+// Provider account and its domains count
+type accountSummary struct {
+	ISP      string `json:"isp"`
+	ID       string `json:"id"`
+	RealID   string `json:"realId"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Domains  int    `json:"domains"`
+}
+
+// Accounts visible by token, nil token sees all of them
+func listAccounts(db *sql.DB, token *apiToken) ([]accountSummary, error) {
+	where, args := token.where()
+	rows, err := db.Query("SELECT isp, id, realId, endpoint, COUNT(*) FROM domain_list WHERE "+where+" GROUP BY isp, id, realId, endpoint ORDER BY isp, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []accountSummary{}
+	for rows.Next() {
+		var account accountSummary
+		if err := rows.Scan(&account.ISP, &account.ID, &account.RealID, &account.Endpoint, &account.Domains); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// GET /accounts
+func (s *apiServer) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	db, err := s.database()
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "api_accounts", "db", s.dbFile, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	accounts, err := listAccounts(db, contextToken(r.Context()))
+	if err != nil {
+		logger.Error("Unable to list accounts", "operation", "api_accounts", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	writeJSON(w, http.StatusOK, accounts)
+}
+
+// GET /health: 200 when DB is populated, 503 otherwise
+func (s *apiServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	var domains int
+	db, err := s.database()
+	if err == nil {
+		err = db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM domain_list").Scan(&domains)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "error", "error": "DB error"})
+		return
+	}
+	if domains == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "error", "error": "DB not populated", "domains": 0})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "domains": domains})
+}
+
+// Serve until ctx is cancelled, then wait for in-flight requests
+func serveAPI(ctx context.Context, listen string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+	logger.Info("API server listening", "operation", "serve", "listen", listen)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("API server shutting down", "operation", "serve")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Context cancelled on SIGINT/SIGTERM, wrapped in order to be able to mock it
+var shutdownContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+type statusResponse struct {
+	// Last sync replacing domains, RFC3339
+	LastSync string           `json:"lastSync"`
+	Domains  int              `json:"domains"`
+	Accounts []accountSummary `json:"accounts"`
+	// Last attempt/success per provider and account
+	Syncs []syncStatus `json:"syncs"`
+}
+
+// Sync status of visible accounts, matching their domain_list rows as failed accounts ones: same id and provider or its ISP variants
+func visibleSyncs(syncs []syncStatus, accounts []accountSummary) []syncStatus {
+	visible := []syncStatus{}
+	for _, accountSync := range syncs {
+		for _, account := range accounts {
+			if account.ID == accountSync.Account && (account.ISP == accountSync.Provider || strings.HasPrefix(account.ISP, accountSync.Provider+"-")) {
+				visible = append(visible, accountSync)
+				break
+			}
+		}
+	}
+	return visible
+}
+
+// GET /status: sync status, counts are restricted to token visible accounts
+func (s *apiServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	db, err := s.database()
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "api_status", "db", s.dbFile, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	accounts, err := listAccounts(db, contextToken(r.Context()))
+	if err != nil {
+		logger.Error("Unable to list accounts", "operation", "api_status", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+
+	syncs, err := listSyncStatus(db)
+	if err != nil {
+		logger.Error("Unable to list sync status", "operation", "api_status", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "DB error")
+		return
+	}
+	// Restricted tokens only see their accounts ones, providers ones are not shown
+	if token := contextToken(r.Context()); token != nil && (len(token.ISPs) > 0 || len(token.Accounts) > 0) {
+		syncs = visibleSyncs(syncs, accounts)
+	}
+
+	response := statusResponse{Accounts: accounts, Syncs: syncs}
+	for _, account := range accounts {
+		response.Domains += account.Domains
+	}
+	if lastSync, err := lastSyncTime(db, s.dbFile); err == nil {
+		response.LastSync = lastSync.UTC().Format(time.RFC3339)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Run API request returning status code and decoded JSON body
+func apiRequest(t *testing.T, handler http.Handler, target string, v interface{}) int {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("%s: expected JSON response, but got: %s", target, w.Header().Get("Content-Type"))
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s: invalid JSON response: %v %s", target, err, w.Body.String())
+	}
+	return w.Code
+}
+
+// Test API endpoints
+func TestAPIHandler(t *testing.T) {
+	db := withTestDb(t)
+	server := newAPIServer(dbFile)
+	defer server.Close()
+	server.auth = false
+	handler := server.handler()
+
+	var domain domainResponse
+	if code := apiRequest(t, handler, "/domains/Example.com", &domain); code != http.StatusOK {
+		t.Errorf("Expected status %d, but got: %d", http.StatusOK, code)
+	}
+	if !domain.Found || len(domain.Holders) != 2 || domain.Holders[0].Domain != "example.com" {
+		t.Errorf("Expected example.com held by 2 accounts, but got: %+v", domain)
+	}
+
+	if code := apiRequest(t, handler, "/domains/notindb.com", &domain); code != http.StatusNotFound || domain.Found {
+		t.Errorf("Expected not found, but got: %d %+v", code, domain)
+	}
+	// Transferred out holders are shown in not found responses
+	if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain, status) VALUES ("3", "3", "godaddy", "gone.com", ?)`, statusTransferredOut); err != nil {
+		t.Fatal(err)
+	}
+	domain = domainResponse{}
+	if code := apiRequest(t, handler, "/domains/gone.com", &domain); code != http.StatusNotFound || domain.Found || len(domain.Holders) != 1 || domain.Holders[0].Status != statusTransferredOut {
+		t.Errorf("Expected transferred out domain not found, but got: %d %+v", code, domain)
+	}
+	if _, err := db.Exec(`DELETE FROM domain_list WHERE domain = "gone.com"`); err != nil {
+		t.Fatal(err)
+	}
+
+	var apiError map[string]string
+	if code := apiRequest(t, handler, "/domains/bad_name.com", &apiError); code != http.StatusBadRequest || apiError["error"] == "" {
+		t.Errorf("Expected invalid domain error, but got: %d %v", code, apiError)
+	}
+
+	var search searchResponse
+	if code := apiRequest(t, handler, "/search?q=example", &search); code != http.StatusOK || len(search.Results) != 3 {
+		t.Errorf("Expected 3 search results, but got: %d %+v", code, search)
+	}
+	if code := apiRequest(t, handler, "/search?q=.net&limit=1", &search); code != http.StatusOK || len(search.Results) != 1 || search.Results[0].Domain != "example.net" {
+		t.Errorf("Expected example.net search result, but got: %d %+v", code, search)
+	}
+	// LIKE wildcards are literal characters
+	if code := apiRequest(t, handler, "/search?q=%25", &search); code != http.StatusOK || len(search.Results) != 0 {
+		t.Errorf("Expected no search results, but got: %d %+v", code, search)
+	}
+	if code := apiRequest(t, handler, "/search", &apiError); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without query, but got: %d", http.StatusBadRequest, code)
+	}
+	if code := apiRequest(t, handler, "/search?q=example&limit=0", &apiError); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for invalid limit, but got: %d", http.StatusBadRequest, code)
+	}
+
+	var accounts []accountSummary
+	if code := apiRequest(t, handler, "/accounts", &accounts); code != http.StatusOK || len(accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, but got: %d %+v", code, accounts)
+	}
+	if accounts[0] != (accountSummary{ISP: "cloudflare", ID: "2", RealID: "otherRealId", Domains: 2}) {
+		t.Errorf("Unexpected cloudflare account: %+v", accounts[0])
+	}
+
+	var health map[string]interface{}
+	if code := apiRequest(t, handler, "/health", &health); code != http.StatusOK || health["status"] != "ok" {
+		t.Errorf("Expected healthy server, but got: %d %v", code, health)
+	}
+}
+
+// Test API requests while sync replaces DB domains, same DB handle sees new ones
+func TestAPIServerDuringSync(t *testing.T) {
+	db := withTestDb(t)
+	mockProvidersSync(t, false)
+	server := newAPIServer(dbFile)
+	defer server.Close()
+	server.auth = false
+	handler := server.handler()
+
+	var domain domainResponse
+	if code := apiRequest(t, handler, "/domains/example.com", &domain); code != http.StatusOK {
+		t.Fatalf("Expected example.com found, but got: %d %+v", code, domain)
+	}
+
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		for i := 0; i < 3; i++ {
+			regenerateDb(dbFile)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-synced:
+			running = false
+		default:
+		}
+		for _, target := range []string{"/domains/example.com", "/accounts", "/status"} {
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code == http.StatusInternalServerError {
+				t.Fatalf("%s: expected no error during sync, but got: %s", target, w.Body.String())
+			}
+		}
+	}
+
+	if code := apiRequest(t, handler, "/domains/new.com", &domain); code != http.StatusOK || !domain.Found {
+		t.Errorf("Expected synced new.com found, but got: %d %+v", code, domain)
+	}
+	if code := apiRequest(t, handler, "/domains/example.com", &domain); code != http.StatusNotFound {
+		t.Errorf("Expected example.com removed by sync, but got: %d %+v", code, domain)
+	}
+
+	if _, err := db.Exec("DELETE FROM domain_list"); err != nil {
+		t.Fatal(err)
+	}
+	var health map[string]interface{}
+	if code := apiRequest(t, handler, "/health", &health); code != http.StatusServiceUnavailable || health["error"] != "DB not populated" {
+		t.Errorf("Expected not populated DB, but got: %d %v", code, health)
+	}
+}
+
+// Test serveAPI graceful shutdown
+func TestServeAPIShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen := listener.Addr().String()
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveAPI(ctx, listen, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		}))
+	}()
+
+	// Wait for server start
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + listen + "/health"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected server running, but got: %v", err)
+	}
+	resp.Body.Close()
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, but got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Server not stopped")
+	}
+}
+
+// Test daemon serving lookups while its scheduled sync replaces DB domains
+func TestServeDuringDaemonSync(t *testing.T) {
+	withTestDb(t)
+	mockProvidersSync(t, false)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listen := listener.Addr().String()
+	listener.Close()
+
+	server := newAPIServer(dbFile)
+	defer server.Close()
+	server.auth = false
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveAPI(ctx, listen, server.handler())
+	}()
+	defer func() {
+		cancel()
+		<-served
+	}()
+
+	get := func(target string) (int, error) {
+		resp, err := http.Get("http://" + listen + target)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	for i := 0; i < 50; i++ {
+		if _, err = get("/health"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected server running, but got: %v", err)
+	}
+
+	// Scheduled syncs as run by daemon command
+	synced := make(chan struct{})
+	go func() {
+		defer close(synced)
+		// Mocked DonDominio fails, other providers domains replace previous ones
+		for i := 0; i < 3; i++ {
+			regenerateDb(dbFile)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-synced:
+			running = false
+		default:
+		}
+		for _, target := range []string{"/domains/new.com", "/search?q=com", "/metrics"} {
+			if code, err := get(target); err != nil || code == http.StatusInternalServerError {
+				t.Fatalf("%s: expected no error during sync, but got: %d %v", target, code, err)
+			}
+		}
+	}
+	if code, _ := get("/domains/new.com"); code != http.StatusOK {
+		t.Errorf("Expected synced new.com found, but got: %d", code)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+// Sync runs and results per provider and account, failed accounts keep their previous domains
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// Provider account sync result, empty account is the provider one
+type accountSync struct {
+	Provider string
+	Account  string
+	Domains  int
+	Err      error
+}
+
+// Current sync results, reset by populateDB
+var syncResults []accountSync
+
+func recordAccountSync(provider, account string, domains int, err error) {
+	syncResults = append(syncResults, accountSync{Provider: provider, Account: account, Domains: domains, Err: err})
+}
+
+// Accounts whose domains couldn't be retrieved in current sync
+func failedAccounts() []accountSync {
+	failed := []accountSync{}
+	for _, result := range syncResults {
+		if result.Err != nil && result.Account != "" {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Account domain_list rows condition, providers use isp variants as ovh-dns one
+const accountRowsWhere = "id = ? AND (isp = ? OR isp LIKE ?)"
+
+func accountRowsArgs(result accountSync) []interface{} {
+	return []interface{}{result.Account, result.Provider, result.Provider + "-%"}
+}
+
+// Account domain_list rows, manual sources are recorded by source and imported rows never belong to provider accounts
+func domainListRows(result accountSync) (string, []interface{}) {
+	if result.Provider == manualProvider {
+		return "source = ?", []interface{}{result.Account}
+	}
+	return accountRowsWhere + " AND source = ''", accountRowsArgs(result)
+}
+
+// Last sync attempt and success per provider and account
+type syncStatus struct {
+	Provider    string `json:"provider"`
+	Account     string `json:"account,omitempty"`
+	LastAttempt string `json:"lastAttempt"`
+	LastSuccess string `json:"lastSuccess,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	Domains     int    `json:"domains"`
+}
+
+// Sync runs kept in sync_runs table
+const syncRunsHistory = 1000
+
+// Sync execution
+type syncRun struct {
+	Started  time.Time
+	Finished time.Time
+	// domain_list was replaced, false when all accounts failed and previous DB was kept
+	Replaced bool
+}
+
+func createSyncTables(db *sql.DB) error {
+	for _, createTableSQL := range []string{
+		`CREATE TABLE IF NOT EXISTS sync_accounts ( "provider" VARCHAR(100), "account" VARCHAR(100), "last_attempt" VARCHAR(30), "last_success" VARCHAR(30) DEFAULT '', "last_error" TEXT DEFAULT '', "domains" INTEGER DEFAULT 0, PRIMARY KEY (provider, account));`,
+		`CREATE TABLE IF NOT EXISTS sync_runs ( "id" INTEGER PRIMARY KEY AUTOINCREMENT, "started" VARCHAR(30), "finished" VARCHAR(30), "success" INTEGER, "replaced" INTEGER, "domains" INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS sync_run_accounts ( "run_id" INTEGER, "provider" VARCHAR(100), "account" VARCHAR(100), "success" INTEGER, "domains" INTEGER, "error" TEXT DEFAULT '');`,
+	} {
+		if _, err := db.Exec(createTableSQL); err != nil {
+			return err
+		}
+	}
+	// Accounts API errors count, sync_accounts tables created by previous versions lack it
+	return migrateTable(db, "sync_accounts", [][2]string{{"errors", "INTEGER DEFAULT 0"}})
+}
+
+// Save sync run and its results, failed accounts keep their last success time and domains count
+func saveSyncResults(db *sql.DB, run syncRun, results []accountSync) error {
+	if err := createSyncTables(db); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	success := true
+	for _, result := range results {
+		if result.Err != nil {
+			success = false
+		}
+	}
+	var domains int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM domain_list").Scan(&domains); err != nil {
+		return err
+	}
+	runResult, err := tx.Exec("INSERT INTO sync_runs (started, finished, success, replaced, domains) VALUES (?, ?, ?, ?, ?)",
+		run.Started.UTC().Format(time.RFC3339), run.Finished.UTC().Format(time.RFC3339), success, run.Replaced, domains)
+	if err != nil {
+		return err
+	}
+	runId, err := runResult.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sync_runs WHERE id <= ?", runId-syncRunsHistory); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sync_run_accounts WHERE run_id <= ?", runId-syncRunsHistory); err != nil {
+		return err
+	}
+
+	at := run.Finished.UTC().Format(time.RFC3339)
+	for _, result := range results {
+		lastSuccess, lastError, errorsCount := at, "", 0
+		if result.Err != nil {
+			lastSuccess, lastError, errorsCount = "", result.Err.Error(), 1
+		}
+		_, err := tx.Exec(`INSERT INTO sync_accounts (provider, account, last_attempt, last_success, last_error, domains, errors) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (provider, account) DO UPDATE SET last_attempt=excluded.last_attempt, last_error=excluded.last_error,
+			last_success=CASE WHEN excluded.last_error='' THEN excluded.last_success ELSE last_success END,
+			domains=CASE WHEN excluded.last_error='' THEN excluded.domains ELSE domains END,
+			errors=errors+excluded.errors`,
+			result.Provider, result.Account, at, lastSuccess, lastError, result.Domains, errorsCount)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec("INSERT INTO sync_run_accounts (run_id, provider, account, success, domains, error) VALUES (?, ?, ?, ?, ?, ?)",
+			runId, result.Provider, result.Account, result.Err == nil, result.Domains, lastError)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Save current sync results to dbFile, nothing is saved when there is no DB yet
+func saveSyncResultsFile(dbFile string, run syncRun) {
+	if !checkFileExists(dbFile) || len(syncResults) == 0 {
+		return
+	}
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		logger.Error("Unable to open DB", "operation", "save_sync", "db", dbFile, "error", err)
+		return
+	}
+	defer db.Close()
+	if err := saveSyncResults(db, run, syncResults); err != nil {
+		logger.Error("Unable to save sync results", "operation", "save_sync", "db", dbFile, "error", err)
+	}
+}
+
+func listSyncStatus(db *sql.DB) ([]syncStatus, error) {
+	if err := createSyncTables(db); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT provider, account, last_attempt, last_success, last_error, domains FROM sync_accounts ORDER BY provider, account")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := []syncStatus{}
+	for rows.Next() {
+		var status syncStatus
+		if err := rows.Scan(&status.Provider, &status.Account, &status.LastAttempt, &status.LastSuccess, &status.LastError, &status.Domains); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// Data age since last sync from which it is considered stale, 0 disables the check, set by -max-age flag
+var maxDataAge = 7 * 24 * time.Hour
+
+// Check if domain_list was synced more than maxAge ago
+func checkStaleDb(db *sql.DB, dbFile string, maxAge time.Duration) (bool, time.Duration) {
+	if maxAge <= 0 {
+		return false, 0
+	}
+	lastSync, err := lastSyncTime(db, dbFile)
+	if err != nil {
+		return false, 0
+	}
+	age := time.Since(lastSync)
+	return age > maxAge, age
+}
+
+// Get last time domain_list was replaced by a sync, DB file modification time for DB files without sync runs
+func lastSyncTime(db *sql.DB, dbFile string) (time.Time, error) {
+	var finished string
+	err := db.QueryRow("SELECT finished FROM sync_runs WHERE replaced ORDER BY id DESC LIMIT 1").Scan(&finished)
+	if err == nil {
+		return time.Parse(time.RFC3339, finished)
+	}
+	// Previous versions DB files
+	if !errors.Is(err, sql.ErrNoRows) && !strings.Contains(err.Error(), "no such table") {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(dbFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Get domain holder account error in last sync, empty when it succeeded
+func lastSyncError(db *sql.DB, record domainRecord) string {
+	var lastError string
+	err := db.QueryRow(`SELECT error FROM sync_run_accounts WHERE run_id = (SELECT MAX(id) FROM sync_runs) AND NOT success
+		AND account = ? AND (provider = ? OR ? LIKE provider || '-%')`, record.ID, record.ISP, record.ISP).Scan(&lastError)
+	if err != nil {
+		return ""
+	}
+	return lastError
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Mock providers populate functions, ovh one fails for ovhId2 account
+func mockProvidersSync(t *testing.T, failing bool) {
+	populateOvhOri := populateOvh
+	populateCloudFlareOri := populateCloudFlare
+	populateGoDaddyOri := populateGoDaddy
+	populateDonDominioOri := populateDonDominio
+	t.Cleanup(func() {
+		populateOvh = populateOvhOri
+		populateCloudFlare = populateCloudFlareOri
+		populateGoDaddy = populateGoDaddyOri
+		populateDonDominio = populateDonDominioOri
+	})
+	// Unmocked providers read an empty configuration directory instead of the user's one
+	configDirOri := configDir
+	t.Cleanup(func() {
+		configDir = configDirOri
+	})
+	configDir = t.TempDir()
+
+	populateOvh = func(db *sql.DB) error {
+		if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("ovhId1", "realId1", "ovh", "new.com")`); err != nil {
+			return err
+		}
+		recordAccountSync("ovh", "ovhId1", 1, nil)
+		if failing {
+			// Partial data is replaced by previous one
+			if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("ovhId2", "realId2", "ovh", "partial.com")`); err != nil {
+				return err
+			}
+			recordAccountSync("ovh", "ovhId2", 0, errors.New("API timeout"))
+			return nil
+		}
+		if _, err := db.Exec(`INSERT INTO domain_list (id, realId, isp, domain) VALUES ("ovhId2", "realId2", "ovh", "second.com"), ("ovhId2", "realId2", "ovh-dns", "zone.com")`); err != nil {
+			return err
+		}
+		recordAccountSync("ovh", "ovhId2", 2, nil)
+		return nil
+	}
+	populateCloudFlare = func(db *sql.DB) error {
+		return nil
+	}
+	populateGoDaddy = func(db *sql.DB) error {
+		return nil
+	}
+	populateDonDominio = func(db *sql.DB) error {
+		return errors.New("File does not exist")
+	}
+}
+
+// Test failed accounts keep their previous domains and sync status is saved
+func TestRegenerateDbFailedAccount(t *testing.T) {
+	db := withTestDb(t)
+
+	mockProvidersSync(t, false)
+	regenerateDb(dbFile)
+
+	syncs, err := listSyncStatus(db)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	lastSuccess := map[string]string{}
+	for _, s := range syncs {
+		lastSuccess[s.Provider+"/"+s.Account] = s.LastSuccess
+	}
+	if lastSuccess["ovh/ovhId2"] == "" || lastSuccess["ovh/"] == "" || lastSuccess["dondominio/"] != "" {
+		t.Fatalf("Unexpected sync status: %+v", syncs)
+	}
+
+	mockProvidersSync(t, true)
+	regenerateDb(dbFile)
+
+	for domain, holders := range map[string]int{"new.com": 1, "second.com": 1, "zone.com": 1, "partial.com": 0, "example.com": 0} {
+		if records, _ := lookupDomain(db, domain); len(records) != holders {
+			t.Errorf("Expected %s held by %d accounts, but got: %+v", domain, holders, records)
+		}
+	}
+
+	// Sync runs history
+	var runs, successfulRuns, runDomains int
+	db.QueryRow("SELECT COUNT(*), SUM(success), MAX(domains) FROM sync_runs WHERE replaced").Scan(&runs, &successfulRuns, &runDomains)
+	if runs != 2 || successfulRuns != 0 || runDomains != 3 {
+		t.Errorf("Expected 2 failed sync runs with 3 domains, but got: %d %d %d", runs, successfulRuns, runDomains)
+	}
+	if lastError := lastSyncError(db, domainRecord{ID: "ovhId2", ISP: "ovh-dns"}); lastError != "API timeout" {
+		t.Errorf("Expected ovhId2 last sync error, but got: %s", lastError)
+	}
+	if lastError := lastSyncError(db, domainRecord{ID: "ovhId1", ISP: "ovh"}); lastError != "" {
+		t.Errorf("Expected no ovhId1 last sync error, but got: %s", lastError)
+	}
+
+	// Lookups warn about failed accounts data
+	exitCode, out := captureRun(t, "search", "zone.com", "new.com")
+	if exitCode != exitFound || !strings.Contains(out, "WARNING: ovh-dns / realId2 failed in last sync, data could be outdated: API timeout") {
+		t.Errorf("Expected failed account warning, but got: %d %s", exitCode, out)
+	}
+	if strings.Count(out, "WARNING") != 1 {
+		t.Errorf("Expected only zone.com warning, but got: %s", out)
+	}
+
+	syncs, _ = listSyncStatus(db)
+	for _, s := range syncs {
+		if s.Provider == "ovh" && s.Account == "ovhId2" {
+			if s.LastError != "API timeout" || s.LastSuccess != lastSuccess["ovh/ovhId2"] || s.Domains != 2 {
+				t.Errorf("Expected failed ovhId2 keeping previous success, but got: %+v", s)
+			}
+		}
+	}
+}
+
+// Test parseSchedule and next
+func TestSchedule(t *testing.T) {
+	from := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC) // Wednesday
+	tests := []struct {
+		expression string
+		next       time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 2 * * 7", time.Date(2024, 2, 4, 2, 30, 0, 0, time.UTC)},
+		{"0 9 1-5 * 1-5", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"5,10 8 * 3 *", time.Date(2024, 3, 1, 8, 5, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+	for _, test := range tests {
+		s, err := parseSchedule(test.expression)
+		if err != nil {
+			t.Errorf("%s: expected no error, but got: %v", test.expression, err)
+			continue
+		}
+		if next := s.next(from); !next.Equal(test.next) {
+			t.Errorf("%s: expected %s, but got: %s", test.expression, test.next, next)
+		}
+	}
+
+	for _, expression := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseSchedule(expression); err == nil || !strings.Contains(err.Error(), "Invalid schedule") {
+			t.Errorf("%s: expected invalid schedule error, but got: %v", expression, err)
+		}
+	}
+}
+
+// Test daemon command invalid schedule
+func TestRunDaemonInvalidSchedule(t *testing.T) {
+	withTestDb(t)
+	if exitCode, out := captureRun(t, "daemon", "-schedule", "* * *"); exitCode != exitUsage || !strings.Contains(out, "Invalid schedule") {
+		t.Errorf("Expected exit code %d for invalid schedule, but got: %d %s", exitUsage, exitCode, out)
+	}
+}
+
+// Test data age is taken from last sync run replacing domains
+func TestCheckStaleDb(t *testing.T) {
+	db := withTestDb(t)
+
+	// DB without sync runs, file modification time is used
+	if stale, _ := checkStaleDb(db, dbFile, time.Hour); stale {
+		t.Errorf("Expected recent DB file not stale")
+	}
+
+	now := time.Now()
+	if err := saveSyncResults(db, syncRun{Started: now.Add(-49 * time.Hour), Finished: now.Add(-48 * time.Hour), Replaced: true}, []accountSync{{Provider: "ovh", Account: "1", Domains: 1}}); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	// Syncs keeping previous DB don't refresh data
+	if err := saveSyncResults(db, syncRun{Started: now.Add(-time.Hour), Finished: now, Replaced: false}, []accountSync{{Provider: "ovh", Account: "1", Err: errors.New("API timeout")}}); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+
+	stale, age := checkStaleDb(db, dbFile, 24*time.Hour)
+	if !stale || age < 48*time.Hour || age > 49*time.Hour {
+		t.Errorf("Expected 48h old stale data, but got: %v %s", stale, age)
+	}
+	if stale, _ := checkStaleDb(db, dbFile, 0); stale {
+		t.Errorf("Expected staleness check disabled")
+	}
+
+	// Shell and -details lookups warn about data age
+	_, out := captureRun(t, "search", "-details", "-max-age", "24h", "example.com")
+	if strings.Count(out, "WARNING: DB is 48h0m0s old") != 1 {
+		t.Errorf("Expected one stale DB warning, but got: %s", out)
+	}
+	if !strings.Contains(out, "WARNING: ovh / realId failed in last sync, data could be outdated: API timeout") {
+		t.Errorf("Expected failed account warning, but got: %s", out)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+// Web UI embedded in binary, it only uses serve command API so it works offline against local DB
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// Web UI files path
+const webPrefix = "/ui/"
+
+func webHandler() http.Handler {
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.StripPrefix(webPrefix, http.FileServer(http.FS(files)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.ServeFileFS(w, r, files, "index.html")
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,161 @@
+"use strict";
+
+// Token is kept in browser local storage, API requests send it as bearer token
+const tokenKey = "domainSearcherToken";
+let domains = [];
+
+const $ = (id) => document.getElementById(id);
+
+async function api(path) {
+  const headers = {};
+  const token = localStorage.getItem(tokenKey);
+  if (token) {
+    headers["Authorization"] = "Bearer " + token;
+  }
+  const response = await fetch(path, { headers });
+  if (response.status === 401) {
+    showLogin("Invalid or missing token");
+    throw new Error("unauthorized");
+  }
+  const body = await response.json();
+  if (!response.ok && response.status !== 404) {
+    throw new Error(body.error || response.statusText);
+  }
+  return body;
+}
+
+function cell(row, text) {
+  const td = document.createElement("td");
+  td.textContent = text || "";
+  row.appendChild(td);
+}
+
+function showLogin(message) {
+  $("app").hidden = true;
+  $("logout").hidden = true;
+  $("login").hidden = false;
+  $("loginError").textContent = message || "";
+  $("token").focus();
+}
+
+async function showStatus() {
+  const status = await api("/status");
+  $("lastSync").textContent = status.lastSync ? new Date(status.lastSync).toLocaleString() : "-";
+  $("domainsCount").textContent = status.domains;
+  const accounts = $("accounts");
+  accounts.replaceChildren();
+  const isps = new Set();
+  for (const account of status.accounts) {
+    const row = document.createElement("tr");
+    cell(row, account.isp);
+    cell(row, account.realId);
+    cell(row, String(account.domains));
+    accounts.appendChild(row);
+    isps.add(account.isp);
+  }
+  const syncs = $("syncs");
+  syncs.replaceChildren();
+  for (const sync of status.syncs) {
+    const row = document.createElement("tr");
+    cell(row, sync.provider);
+    cell(row, sync.account || "*");
+    cell(row, sync.lastSuccess ? new Date(sync.lastSuccess).toLocaleString() : "never");
+    if (sync.lastError) {
+      row.className = "error";
+      row.title = sync.lastError;
+    }
+    syncs.appendChild(row);
+  }
+  const select = $("isp");
+  select.replaceChildren(select.options[0]);
+  for (const isp of [...isps].sort()) {
+    select.appendChild(new Option(isp, isp));
+  }
+}
+
+// Live filtering is done in browser over the whole inventory
+function filterDomains() {
+  const query = $("search").value.trim().toLowerCase();
+  const isp = $("isp").value;
+  const matches = domains.filter((d) => d.domain.includes(query) && (isp === "" || d.isp === isp));
+
+  const tbody = $("domains");
+  tbody.replaceChildren();
+  for (const d of matches.slice(0, 500)) {
+    const row = document.createElement("tr");
+    cell(row, d.domain);
+    cell(row, d.isp);
+    cell(row, d.realId);
+    cell(row, d.expires);
+    row.addEventListener("click", () => showDetail(d.domain));
+    tbody.appendChild(row);
+  }
+  $("results").textContent = matches.length > 500 ? `${matches.length} domains, showing first 500` : `${matches.length} domains`;
+}
+
+async function showDetail(name) {
+  $("inventory").hidden = true;
+  $("detail").hidden = false;
+  $("detailDomain").textContent = name;
+  $("holders").replaceChildren();
+  $("ns").replaceChildren();
+  $("whois").textContent = "Loading...";
+  $("detailErrors").textContent = "";
+
+  const detail = await api("/domains/" + encodeURIComponent(name) + "?details=true");
+  for (const holder of detail.holders) {
+    const row = document.createElement("tr");
+    cell(row, holder.isp);
+    cell(row, holder.id);
+    cell(row, holder.realId);
+    cell(row, holder.endpoint);
+    cell(row, holder.expires);
+    cell(row, holder.source ? `manual: ${holder.source}` : "API");
+    $("holders").appendChild(row);
+  }
+  for (const ns of detail.ns || []) {
+    const li = document.createElement("li");
+    li.textContent = ns;
+    $("ns").appendChild(li);
+  }
+  $("whois").textContent = detail.whois || "";
+  $("detailErrors").textContent = (detail.detailsErrors || []).join(", ");
+}
+
+function closeDetail() {
+  $("detail").hidden = true;
+  $("inventory").hidden = false;
+  $("search").focus();
+}
+
+async function load() {
+  try {
+    await showStatus();
+    domains = await api("/domains");
+  } catch (error) {
+    if (error.message !== "unauthorized") {
+      $("results").textContent = error.message;
+    }
+    return;
+  }
+  $("login").hidden = true;
+  $("app").hidden = false;
+  $("logout").hidden = !localStorage.getItem(tokenKey);
+  filterDomains();
+}
+
+$("login").addEventListener("submit", (event) => {
+  event.preventDefault();
+  localStorage.setItem(tokenKey, $("token").value.trim());
+  $("token").value = "";
+  load();
+});
+$("logout").addEventListener("click", () => {
+  localStorage.removeItem(tokenKey);
+  showLogin();
+});
+$("search").addEventListener("input", filterDomains);
+$("isp").addEventListener("change", filterDomains);
+$("closeDetail").addEventListener("click", closeDetail);
+
+load();
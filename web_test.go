@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/twiny/whois/v2"
+)
+
+// Test web UI files are served without token
+func TestWebUI(t *testing.T) {
+	withTestDb(t)
+	server := newAPIServer(dbFile)
+	defer server.Close()
+	server.accessLog = newLogger(io.Discard, logText, slog.LevelInfo)
+	handler := server.handler()
+
+	for path, expected := range map[string]string{
+		"/":              "<title>domainSearcher</title>",
+		"/ui/app.js":     "localStorage",
+		"/ui/style.css":  "body {",
+		"/ui/index.html": "",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if path == "/ui/index.html" {
+			// FileServer redirects index.html requests to directory
+			if w.Code != http.StatusMovedPermanently {
+				t.Errorf("%s: expected redirect, but got: %d", path, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("%s: expected %s, but got: %d %s", path, expected, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/domains", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected domains list requiring token, but got: %d", w.Code)
+	}
+}
+
+// Test web UI API endpoints: domains list, domain details and sync status
+func TestWebUIAPI(t *testing.T) {
+	db := withTestDb(t)
+	if _, err := db.Exec(`UPDATE domain_list SET expires="2030-01-01" WHERE domain="example.net"`); err != nil {
+		t.Fatal(err)
+	}
+	token, _ := addToken(db, "cloudflare", []string{"cloudflare"}, nil)
+
+	getDnsNsOri := getDnsNs
+	getWhoisOri := getWhois
+	defer func() {
+		getDnsNs = getDnsNsOri
+		getWhois = getWhoisOri
+	}()
+	getDnsNs = func(domainToSearch string) ([]*net.NS, error) {
+		return []*net.NS{{Host: "ns1.example.com."}}, nil
+	}
+	getWhois = func(domainToSearch string) (whois.Response, error) {
+		return whois.Response{}, errors.New("whois timeout")
+	}
+
+	server := newAPIServer(dbFile)
+	defer server.Close()
+	server.accessLog = newLogger(io.Discard, logText, slog.LevelInfo)
+	handler := server.handler()
+
+	request := func(target string, v interface{}) int {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: invalid JSON response: %v %s", target, err, w.Body.String())
+		}
+		return w.Code
+	}
+
+	var domains []domainRecord
+	if code := request("/domains", &domains); code != http.StatusOK || len(domains) != 2 {
+		t.Fatalf("Expected 2 cloudflare domains, but got: %d %+v", code, domains)
+	}
+	if domains[1].Domain != "example.net" || domains[1].Expires != "2030-01-01" {
+		t.Errorf("Expected example.net with expiry date, but got: %+v", domains[1])
+	}
+
+	var detail domainResponse
+	if code := request("/domains/example.net?details=true", &detail); code != http.StatusOK {
+		t.Errorf("Expected status %d, but got: %d", http.StatusOK, code)
+	}
+	if strings.Join(detail.NS, ",") != "ns1.example.com." || len(detail.DetailsErrors) != 1 || detail.DetailsErrors[0] != "WHOIS: whois timeout" {
+		t.Errorf("Expected NS servers and WHOIS error, but got: %+v", detail)
+	}
+
+	var status statusResponse
+	if code := request("/status", &status); code != http.StatusOK || status.Domains != 2 || len(status.Accounts) != 1 || status.LastSync == "" {
+		t.Errorf("Expected cloudflare sync status, but got: %d %+v", code, status)
+	}
+}